@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"fmt"
 	"os"
 
 	"k8s.io/klog/v2"
@@ -25,6 +26,7 @@ import (
 	"github.com/kubeedge/mappers-go/mappers/Template/device"
 	"github.com/kubeedge/mappers-go/mappers/Template/globals"
 	"github.com/kubeedge/mappers-go/mappers/common"
+	"github.com/kubeedge/mappers-go/mappers/runtime"
 )
 
 func main() {
@@ -40,6 +42,15 @@ func main() {
 	}
 	klog.V(4).Info(c.Configmap)
 
+	if c.ValidateOnly {
+		if err = device.DevValidate(c.Configmap); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println(c.Configmap, "is valid")
+		return
+	}
+
 	globals.MqttClient = common.MqttClient{IP: c.Mqtt.ServerAddress,
 		User:       c.Mqtt.Username,
 		Passwd:     c.Mqtt.Password,
@@ -50,9 +61,13 @@ func main() {
 		os.Exit(1)
 	}
 
+	globals.HTTPAddress = c.HTTP.Address
+	globals.HTTPToken = c.HTTP.Token
+	globals.ReadyMaxDisconnected = c.HTTP.ReadyMaxDisconnected
+	globals.Strict = c.Strict
 	if err = device.DevInit(c.Configmap); err != nil {
 		klog.Fatal(err)
 		os.Exit(1)
 	}
-	device.DevStart()
+	device.DevStart(runtime.SetupSignalContext())
 }
@@ -5,30 +5,30 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
-	"github.com/kubeedge/mappers-go/mappers/Template/globals"
 	"github.com/kubeedge/mappers-go/mappers/common"
+	"github.com/kubeedge/mappers-go/mappers/runtime"
 )
 
 func TestParse(t *testing.T) {
-	var devices map[string]*globals.TemplateDev
+	var devices map[string]*runtime.Device
 	var models map[string]common.DeviceModel
 	var protocols map[string]common.Protocol
 
-	devices = make(map[string]*globals.TemplateDev)
+	devices = make(map[string]*runtime.Device)
 	models = make(map[string]common.DeviceModel)
 	protocols = make(map[string]common.Protocol)
 
-	assert.Nil(t, Parse("./configmap_test.json", devices, models, protocols))
+	assert.Nil(t, runtime.Parse("./configmap_test.json", devices, models, protocols))
 }
 
 func TestParseNeg(t *testing.T) {
-	var devices map[string]*globals.TemplateDev
+	var devices map[string]*runtime.Device
 	var models map[string]common.DeviceModel
 	var protocols map[string]common.Protocol
 
-	devices = make(map[string]*globals.TemplateDev)
+	devices = make(map[string]*runtime.Device)
 	models = make(map[string]common.DeviceModel)
 	protocols = make(map[string]common.Protocol)
 
-	assert.NotNil(t, Parse("./configmap_negtest.json", devices, models, protocols))
+	assert.NotNil(t, runtime.Parse("./configmap_negtest.json", devices, models, protocols))
 }
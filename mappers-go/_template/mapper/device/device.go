@@ -15,98 +15,36 @@ limitations under the License.
 */
 
 /*
-* TODO: This file is to control the devices. Please refine the configurations and
+* TODO: This file is the driver of the devices. Please refine the configurations and
 * reading/writing functions.
  */
 package device
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"regexp"
-	"sync"
-	"time"
-
-	mqtt "github.com/eclipse/paho.mqtt.golang"
-	"k8s.io/klog/v2"
 
 	"github.com/kubeedge/mappers-go/mappers/Template/configmap"
 	"github.com/kubeedge/mappers-go/mappers/Template/driver"
 	"github.com/kubeedge/mappers-go/mappers/Template/globals"
 	"github.com/kubeedge/mappers-go/mappers/common"
+	"github.com/kubeedge/mappers-go/mappers/runtime"
 )
 
-var devices map[string]*globals.TemplateDev
-var models map[string]common.DeviceModel
-var protocols map[string]common.Protocol
-var wg sync.WaitGroup
-
-// setVisitor check if visitory property is readonly, if not then set it.
-func setVisitor(visitorConfig *configmap.TemplateVisitorConfig, twin *common.Twin, client *driver.TemplateClient) {
-	if twin.PVisitor.PProperty.AccessMode == "ReadOnly" {
-		return
-	}
-
-	// TODO
-	_, err := client.Set()
-	if err != nil {
-		klog.Errorf("Set error: %v, %v", err, visitorConfig)
-		return
-	}
-}
-
-// getDeviceID extract the device ID from Mqtt topic.
-func getDeviceID(topic string) (id string) {
-	re := regexp.MustCompile(`hw/events/device/(.+)/twin/update/delta`)
-	return re.FindStringSubmatch(topic)[1]
-}
-
-// onMessage callback function of Mqtt subscribe message.
-func onMessage(client mqtt.Client, message mqtt.Message) {
-	klog.V(2).Info("Receive message", message.Topic())
-	// Get device ID and get device instance
-	id := getDeviceID(message.Topic())
-	if id == "" {
-		klog.Error("Wrong topic")
-		return
-	}
-	klog.V(2).Info("Device id: ", id)
+// Driver is the Template driver of the mapper runtime.
+type Driver struct{}
 
-	var dev *globals.TemplateDev
-	var ok bool
-	if dev, ok = devices[id]; !ok {
-		klog.Error("Device not exist")
-		return
-	}
+var mapper = runtime.NewMapper(&Driver{}, &globals.MqttClient)
 
-	// Get twin map key as the propertyName
-	var delta common.DeviceTwinDelta
-	if err := json.Unmarshal(message.Payload(), &delta); err != nil {
-		klog.Errorf("Unmarshal message failed: %v", err)
-		return
-	}
-	for twinName, twinValue := range delta.Delta {
-		i := 0
-		for i = 0; i < len(dev.Instance.Twins); i++ {
-			if twinName == dev.Instance.Twins[i].PropertyName {
-				break
-			}
-		}
-		if i == len(dev.Instance.Twins) {
-			klog.Error("Twin not found: ", twinName)
-			continue
-		}
-		// Desired value is not changed.
-		if dev.Instance.Twins[i].Desired.Value == twinValue {
-			continue
-		}
-		dev.Instance.Twins[i].Desired.Value = twinValue
-		var visitorConfig configmap.TemplateVisitorConfig
-		if err := json.Unmarshal([]byte(dev.Instance.Twins[i].PVisitor.VisitorConfig), &visitorConfig); err != nil {
-			klog.Errorf("Unmarshal VisitorConfig error: %v", err)
-		}
-		setVisitor(&visitorConfig, &dev.Instance.Twins[i], dev.TemplateClient)
+// ParseVisitor parse the visitor config of the property.
+func (d *Driver) ParseVisitor(dev *runtime.Device, prop *runtime.Property) error {
+	var visitorConfig configmap.TemplateVisitorConfig
+	if err := json.Unmarshal([]byte(prop.Visitor.VisitorConfig), &visitorConfig); err != nil {
+		return fmt.Errorf("unmarshal VisitorConfig error: %v", err)
 	}
+	prop.Config = &visitorConfig
+	return nil
 }
 
 // initTemplate initialize Template client.
@@ -117,126 +55,85 @@ func initTemplate(protocolConfig configmap.TemplateProtocolCommonConfig) (client
 	return client, nil
 }
 
-// initTwin initialize the timer to get twin value.
-func initTwin(dev *globals.TemplateDev) {
-	for i := 0; i < len(dev.Instance.Twins); i++ {
-		var visitorConfig configmap.TemplateVisitorConfig
-		if err := json.Unmarshal([]byte(dev.Instance.Twins[i].PVisitor.VisitorConfig), &visitorConfig); err != nil {
-			klog.Errorf("Unmarshal VisitorConfig error: %v", err)
-			continue
-		}
-		setVisitor(&visitorConfig, &dev.Instance.Twins[i], dev.TemplateClient)
-
-		// TODO: pass the twin values to the timer.
-		twinData := TwinData{Client: dev.TemplateClient,
-			Name:  dev.Instance.Twins[i].PropertyName,
-			Type:  dev.Instance.Twins[i].Desired.Metadatas.Type,
-			Topic: fmt.Sprintf(common.TopicTwinUpdate, dev.Instance.ID)}
-		collectCycle := time.Duration(dev.Instance.Twins[i].PVisitor.CollectCycle)
-		// If the collect cycle is not set, set it to 1 second.
-		if collectCycle == 0 {
-			collectCycle = 1 * time.Second
-		}
-		timer := common.Timer{Function: twinData.Run, Duration: collectCycle, Times: 0}
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			timer.Start()
-		}()
-	}
-}
-
-// initData initialize the timer to get data.
-func initData(dev *globals.TemplateDev) {
-	for i := 0; i < len(dev.Instance.Datas.Properties); i++ {
-		var visitorConfig configmap.TemplateVisitorConfig
-		if err := json.Unmarshal([]byte(dev.Instance.Datas.Properties[i].PVisitor.VisitorConfig), &visitorConfig); err != nil {
-			klog.Errorf("Unmarshal VisitorConfig error: %v", err)
-		}
-
-		// TODO: pass the data values to the timer.
-		twinData := TwinData{Client: dev.TemplateClient,
-			Name:  dev.Instance.Datas.Properties[i].PropertyName,
-			Type:  dev.Instance.Datas.Properties[i].Metadatas.Type,
-			Topic: fmt.Sprintf(common.TopicDataUpdate, dev.Instance.ID)}
-		collectCycle := time.Duration(dev.Instance.Datas.Properties[i].PVisitor.CollectCycle)
-		// If the collect cycle is not set, set it to 1 second.
-		if collectCycle == 0 {
-			collectCycle = 1 * time.Second
-		}
-		timer := common.Timer{Function: twinData.Run, Duration: collectCycle, Times: 0}
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			timer.Start()
-		}()
-	}
-}
-
-// initSubscribeMqtt subscribe Mqtt topics.
-func initSubscribeMqtt(instanceID string) error {
-	topic := fmt.Sprintf(common.TopicTwinUpdateDelta, instanceID)
-	klog.V(1).Info("Subscribe topic: ", topic)
-	return globals.MqttClient.Subscribe(topic, onMessage)
-}
-
-// initGetStatus start timer to get device status and send to eventbus.
-func initGetStatus(dev *globals.TemplateDev) {
-	getStatus := GetStatus{Client: dev.TemplateClient,
-		topic: fmt.Sprintf(common.TopicStateUpdate, dev.Instance.ID)}
-	timer := common.Timer{Function: getStatus.Run, Duration: 1 * time.Second, Times: 0}
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		timer.Start()
-	}()
-}
-
-// start start the device.
-func start(dev *globals.TemplateDev) {
+// Connect create the client of the device.
+func (d *Driver) Connect(dev *runtime.Device) error {
 	var protocolCommConfig configmap.TemplateProtocolCommonConfig
 	if err := json.Unmarshal([]byte(dev.Instance.PProtocol.ProtocolCommonConfig), &protocolCommConfig); err != nil {
-		klog.Errorf("Unmarshal ProtocolCommonConfig error: %v", err)
-		return
+		return fmt.Errorf("unmarshal ProtocolCommonConfig error: %v", err)
 	}
 
 	var protocolConfig configmap.TemplateProtocolConfig
 	if err := json.Unmarshal([]byte(dev.Instance.PProtocol.ProtocolConfigs), &protocolConfig); err != nil {
-		klog.Errorf("Unmarshal ProtocolConfig error: %v", err)
-		return
+		return fmt.Errorf("unmarshal ProtocolConfig error: %v", err)
 	}
 
 	client, err := initTemplate(protocolCommConfig)
 	if err != nil {
-		klog.Errorf("Init error: %v", err)
-		return
+		return err
 	}
-	dev.TemplateClient = client
+	dev.Client = client
+	return nil
+}
 
-	initTwin(dev)
-	initData(dev)
+// ReadProperty get the value of the property.
+func (d *Driver) ReadProperty(dev *runtime.Device, prop *runtime.Property) (string, error) {
+	client := dev.Client.(*driver.TemplateClient)
+	// TODO: pass the visiting parameters in prop.Config to the client and transfer the results.
+	results, err := client.Get()
+	if err != nil {
+		return "", err
+	}
+	return string(results), nil
+}
 
-	if err := initSubscribeMqtt(dev.Instance.ID); err != nil {
-		klog.Errorf("Init subscribe mqtt error: %v", err)
-		return
+// WriteProperty set the value of the property.
+func (d *Driver) WriteProperty(dev *runtime.Device, prop *runtime.Property, value string) error {
+	client := dev.Client.(*driver.TemplateClient)
+	// TODO: pass the visiting parameters in prop.Config and the value to the client.
+	_, err := client.Set()
+	return err
+}
+
+// GetStatus get the status of the device.
+func (d *Driver) GetStatus(dev *runtime.Device) string {
+	client, ok := dev.Client.(*driver.TemplateClient)
+	if !ok {
+		return common.DEVSTUNKNOWN
 	}
+	return client.GetStatus()
+}
 
-	initGetStatus(dev)
+// Close release the client of the device.
+func (d *Driver) Close(dev *runtime.Device) error {
+	// TODO: close the connection to the device.
+	return nil
+}
+
+// ProtocolSchema return the types of the protocol configs, to validate the device profile.
+func (d *Driver) ProtocolSchema() (interface{}, interface{}) {
+	return &configmap.TemplateProtocolConfig{}, &configmap.TemplateProtocolCommonConfig{}
+}
+
+// VisitorSchema return the type of the visitor configs, to validate the device profile.
+func (d *Driver) VisitorSchema() interface{} {
+	return &configmap.TemplateVisitorConfig{}
 }
 
 // DevInit initialize the device datas.
 func DevInit(configmapPath string) error {
-	devices = make(map[string]*globals.TemplateDev)
-	models = make(map[string]common.DeviceModel)
-	protocols = make(map[string]common.Protocol)
-	return configmap.Parse(configmapPath, devices, models, protocols)
+	mapper.HTTPAddress = globals.HTTPAddress
+	mapper.HTTPToken = globals.HTTPToken
+	mapper.ReadyMaxDisconnected = globals.ReadyMaxDisconnected
+	mapper.Strict = globals.Strict
+	return mapper.Init(configmapPath)
 }
 
-// DevStart start all devices.
-func DevStart() {
-	for id, dev := range devices {
-		klog.V(4).Info("Dev: ", id, dev)
-		start(dev)
-	}
-	wg.Wait()
+// DevValidate check the device profile of the configmap, and return all its problems.
+func DevValidate(configmapPath string) error {
+	return mapper.Validate(configmapPath)
+}
+
+// DevStart start all devices and block until the context is cancelled.
+func DevStart(ctx context.Context) {
+	mapper.Start(ctx)
 }
@@ -17,14 +17,18 @@ limitations under the License.
 package globals
 
 import (
-	"github.com/kubeedge/mappers-go/mappers/Template/driver"
 	"github.com/kubeedge/mappers-go/mappers/common"
 )
 
-// TemplateDev is the Template device configuration and client information.
-type TemplateDev struct {
-	Instance       common.DeviceInstance
-	TemplateClient *driver.TemplateClient
-}
-
 var MqttClient common.MqttClient
+
+// HTTPAddress and HTTPToken configure the embedded HTTP server of the mapper.
+var HTTPAddress string
+
+var HTTPToken string
+
+// ReadyMaxDisconnected is the percentage of disconnected devices above which the mapper is not ready.
+var ReadyMaxDisconnected int
+
+// Strict refuses a configmap with any problem instead of starting the valid devices.
+var Strict bool
@@ -6,13 +6,16 @@ require (
 	github.com/beevik/etree v1.1.0
 	github.com/currantlabs/ble v0.0.0-20171229162446-c1d21c164cf8
 	github.com/eclipse/paho.mqtt.golang v1.3.0
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/golang/groupcache v0.0.0-20200121045136-8c9f03a8e57e // indirect
+	github.com/google/uuid v1.1.1
 	github.com/gopcua/opcua v0.1.13
 	github.com/kubeedge/kubeedge v1.5.0
 	github.com/mgutz/ansi v0.0.0-20200706080929-d51e80ef957d // indirect
 	github.com/mgutz/logxi v0.0.0-20161027140823-aebf8a7d67ab // indirect
 	github.com/onsi/ginkgo v1.15.0
 	github.com/onsi/gomega v1.10.5
+	github.com/prometheus/client_golang v1.7.1
 	github.com/sailorvii/goav v0.1.4
 	github.com/sailorvii/modbus v0.1.2
 	github.com/spf13/pflag v1.0.5
@@ -22,7 +25,9 @@ require (
 	google.golang.org/protobuf v1.25.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0
 	k8s.io/api v0.19.3
+	k8s.io/apimachinery v0.19.3
 	k8s.io/klog/v2 v2.4.0
+	sigs.k8s.io/yaml v1.2.0
 )
 
 replace (
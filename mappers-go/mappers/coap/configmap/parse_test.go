@@ -6,20 +6,20 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
-	"github.com/kubeedge/mappers-go/mappers/coap/globals"
 	"github.com/kubeedge/mappers-go/mappers/common"
+	"github.com/kubeedge/mappers-go/mappers/runtime"
 )
 
 func TestParse(t *testing.T) {
-	var devices map[string]*globals.CoapDev
+	var devices map[string]*runtime.Device
 	var models map[string]common.DeviceModel
 	var protocols map[string]common.Protocol
 
-	devices = make(map[string]*globals.CoapDev)
+	devices = make(map[string]*runtime.Device)
 	models = make(map[string]common.DeviceModel)
 	protocols = make(map[string]common.Protocol)
 
-	assert.Nil(t, Parse("./configmap_test.json", devices, models, protocols))
+	assert.Nil(t, runtime.Parse("./configmap_test.json", devices, models, protocols))
 	for _, device := range devices {
 		var pcc CoapProtocolCommonConfig
 		assert.Nil(t, json.Unmarshal([]byte(device.Instance.PProtocol.ProtocolCommonConfig), &pcc))
@@ -28,13 +28,13 @@ func TestParse(t *testing.T) {
 }
 
 func TestParseNeg(t *testing.T) {
-	var devices map[string]*globals.CoapDev
+	var devices map[string]*runtime.Device
 	var models map[string]common.DeviceModel
 	var protocols map[string]common.Protocol
 
-	devices = make(map[string]*globals.CoapDev)
+	devices = make(map[string]*runtime.Device)
 	models = make(map[string]common.DeviceModel)
 	protocols = make(map[string]common.Protocol)
 
-	assert.NotNil(t, Parse("./configmap_negtest.json", devices, models, protocols))
+	assert.NotNil(t, runtime.Parse("./configmap_negtest.json", devices, models, protocols))
 }
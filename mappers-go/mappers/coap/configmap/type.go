@@ -16,14 +16,21 @@ limitations under the License.
 
 package configmap
 
+import "github.com/kubeedge/mappers-go/mappers/common"
+
 // CoapVisitorConfig is the coap register configuration.
 type CoapVisitorConfig struct {
 	ProtocolName      string `json:"protocolName"`
 	VisitorConfigData `json:"configData"`
+	// Codec decodes the payloads of the path, and encodes the written values if the codec
+	// config is set.
+	Codec *common.Codec `json:"-"`
 }
 
 type VisitorConfigData struct {
 	PathField string `json:"pathField,omitempty"`
+	// CodecConfig is how the value is encoded in the payload.
+	common.CodecConfig
 }
 
 // CoapProtocolConfig is the protocol configuration.
@@ -0,0 +1,28 @@
+package device
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kubeedge/mappers-go/mappers/coap/configmap"
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+func TestEncodeValue(t *testing.T) {
+	newConfig := func(config common.CodecConfig) *configmap.CoapVisitorConfig {
+		codec, err := common.NewCodec("int", config)
+		assert.Nil(t, err)
+		return &configmap.CoapVisitorConfig{Codec: codec,
+			VisitorConfigData: configmap.VisitorConfigData{PathField: "temperature", CodecConfig: config}}
+	}
+
+	// Without codec config, the value is sent as text.
+	payload, err := encodeValue(newConfig(common.CodecConfig{}), "25")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("25"), payload)
+
+	payload, err = encodeValue(newConfig(common.CodecConfig{Width: 2}), "25")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x00, 0x19}, payload)
+}
@@ -18,6 +18,7 @@ package driver
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 
 	"k8s.io/klog/v2"
@@ -44,28 +45,39 @@ type CoapClient struct {
 
 var clients map[string]*CoapClient
 
+// refs counts the devices sharing the client of an address.
+var refs map[string]int
+
+// clientsMu protects clients and refs.
+var clientsMu sync.Mutex
+
 func newCoapClient(config CoapConfig) (*CoapClient, error) {
 	addr := config.ServerAddress
 	var coapClient *coap.Conn
 	var err error
 
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
 	if client, ok := clients[addr]; ok {
+		refs[addr]++
 		return client, nil
 	}
 
 	if clients == nil {
 		clients = make(map[string]*CoapClient)
+		refs = make(map[string]int)
 	}
 
 	//coapClient, err = coap.Dial("udp", "localhost:5683")
 	coapClient, err = coap.Dial("udp", addr)
 	if err != nil {
-		//log.Fatalf("Error dialing: %v", err)
-		klog.Fatal(err)
+		return nil, fmt.Errorf("dial %s: %v", addr, err)
 	}
 
 	client := CoapClient{Client: coapClient, Config: config} //, Path: config.Path}
 	clients[addr] = &client
+	refs[addr] = 1
 	return &client, err
 }
 
@@ -79,6 +91,25 @@ func NewClient(config interface{}) (*CoapClient, error) {
 	}
 }
 
+// Close release the client, the connection is closed when no device uses it.
+func (c *CoapClient) Close() error {
+	coapConfig, _ := c.Config.(CoapConfig)
+	addr := coapConfig.ServerAddress
+
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	if clients[addr] != c {
+		return nil
+	}
+	if refs[addr]--; refs[addr] > 0 {
+		return nil
+	}
+	delete(clients, addr)
+	delete(refs, addr)
+	return c.Client.Close()
+}
+
 // GetStatus get device status.
 // Coap don't know status, so always return good
 func (c *CoapClient) GetStatus() string {
@@ -118,7 +149,7 @@ func (c *CoapClient) Get(path string) (results []byte, err error) {
 	}
 
 	if rv != nil {
-		klog.V(2).Info("Response payload: %s", rv.Payload)
+		klog.V(2).Infof("Response payload: %s", rv.Payload)
 		return rv.Payload, err
 	}
 
@@ -22,60 +22,34 @@ package driver
 import (
 	"fmt"
 	"os"
-	"time"
 )
 
 func tdriver() {
-	var coaprtu CoapRTU
+	var config CoapConfig
 
-	coaprtu.SerialName = "/dev/ttyS0"
-	coaprtu.BaudRate = 9600
-	coaprtu.DataBits = 8
-	coaprtu.StopBits = 1
-	coaprtu.SlaveID = 1
-	coaprtu.Parity = "N"
-	coaprtu.Timeout = 2 * time.Second
+	config.ServerAddress = "127.0.0.1:5683"
 
-	client, err := NewClient(coaprtu)
+	client, err := NewClient(config)
 	if err != nil {
 		fmt.Println("New client error")
 		os.Exit(1)
 	}
 
-	results, err := client.Set("DiscreteInputRegister", 2, 1)
+	results, err := client.Set("temperature-enable", "1")
 	if err != nil {
 		fmt.Println(err)
 	}
 	fmt.Println(results)
-	results, err = client.Set("CoilRegister", 2, 1)
+	results, err = client.Get("temperature")
 	if err != nil {
 		fmt.Println(err)
 	}
 	fmt.Println(results)
+	client.Close()
 	os.Exit(0)
 }
 
 func main() {
-	/*
-		var coaptcp CoapTCP
-
-		coaptcp.DeviceIp = "192.168.56.1"
-		coaptcp.TcpPort = "502"
-		coaptcp.SlaveId = 0x1
-		client := NewClient(coaptcp)
-		if client == nil {
-			fmt.Println("New client error")
-			os.Exit(1)
-		}
-		fmt.Println("status: ", client.GetStatus())
-
-		results, err := client.Client.ReadDiscreteInputs(0, 1)
-		if err != nil {
-			fmt.Println("Read error: ", err)
-			os.Exit(1)
-		}
-		fmt.Println("result: ", results)
-	*/
 	tdriver()
 	os.Exit(0)
 }
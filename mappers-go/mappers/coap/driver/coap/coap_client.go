@@ -64,3 +64,8 @@ func (c *Conn) Receive() (*Message, error) {
 	}
 	return &rv, nil
 }
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
@@ -17,17 +17,20 @@ limitations under the License.
 package globals
 
 import (
-	"github.com/kubeedge/mappers-go/mappers/coap/driver"
 	"github.com/kubeedge/mappers-go/mappers/common"
 )
 
-// CoapDev is the coap device configuration and client information.
-type CoapDev struct {
-	Instance   common.DeviceInstance
-	CoapClient *driver.CoapClient
-	//Path       string
-}
-
 var MqttClient common.MqttClient
 
 var LocalTest bool
+
+// HTTPAddress and HTTPToken configure the embedded HTTP server of the mapper.
+var HTTPAddress string
+
+var HTTPToken string
+
+// ReadyMaxDisconnected is the percentage of disconnected devices above which the mapper is not ready.
+var ReadyMaxDisconnected int
+
+// Strict refuses a configmap with any problem instead of starting the valid devices.
+var Strict bool
@@ -0,0 +1,363 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Byte orders of CodecConfig.
+const (
+	ByteOrderBig    = "big"
+	ByteOrderLittle = "little"
+)
+
+// Raw types of CodecConfig.
+const (
+	RawTypeInteger = "integer"
+	RawTypeFloat   = "float"
+)
+
+// CodecConfig is how the value of a property is encoded in the bytes of the device, such
+// as a register. It is set in the configData of the visitor config.
+type CodecConfig struct {
+	// ByteOrder is ByteOrderBig, the default, or ByteOrderLittle.
+	ByteOrder string `json:"byteOrder,omitempty"`
+	// WordSwap reverses the order of the 16 bits words, for the devices which keep the
+	// low word of a 32 or 64 bits value first.
+	WordSwap bool `json:"wordSwap,omitempty"`
+	// Width is the number of bytes of the raw value. A numeric raw value is 1, 2, 4 or 8
+	// bytes. When it is 0, the whole bytes are decoded, and values are encoded in the
+	// width of the data type.
+	Width int `json:"width,omitempty"`
+	// RawType is RawTypeInteger or RawTypeFloat, for IEEE 754 raw values. The default is
+	// float for the float and double data types, and integer for the other numeric types.
+	// When it is set, the raw value is a number even if the data type isn't, such as
+	// for the string property an expression makes of the number.
+	RawType string `json:"rawType,omitempty"`
+	// Signed decodes the raw integer, or its bit field, as two's complement.
+	Signed bool `json:"signed,omitempty"`
+	// BitOffset and BitLength extract a bit field of the raw integer, counted from the
+	// least significant bit. The whole integer is used if BitLength is 0.
+	BitOffset int `json:"bitOffset,omitempty"`
+	BitLength int `json:"bitLength,omitempty"`
+	// Scale and Offset transform a numeric raw value into the property value, which is
+	// raw*Scale+Offset. Scale is 1 if it is 0.
+	Scale  float64 `json:"scale,omitempty"`
+	Offset float64 `json:"offset,omitempty"`
+}
+
+// Codec decodes the bytes of the device into the values of a property, and encodes the
+// values into bytes again, as its CodecConfig says.
+type Codec struct {
+	config   CodecConfig
+	dataType string
+	// float is set for IEEE 754 raw values.
+	float bool
+}
+
+// NewCodec return the codec of the property values of the data type.
+func NewCodec(dataType string, config CodecConfig) (*Codec, error) {
+	c := &Codec{config: config, dataType: dataType}
+	if c.config.Scale == 0 {
+		c.config.Scale = 1
+	}
+	switch config.ByteOrder {
+	case "", ByteOrderBig, ByteOrderLittle:
+	default:
+		return nil, fmt.Errorf("byteOrder must be %s or %s instead of %q", ByteOrderBig, ByteOrderLittle, config.ByteOrder)
+	}
+	if !c.numeric() {
+		if config.Signed || config.BitLength != 0 || config.Scale != 0 || config.Offset != 0 {
+			return nil, fmt.Errorf("signed, bit field, scale and offset are for numeric values, not %s", dataType)
+		}
+		return c, nil
+	}
+
+	switch config.RawType {
+	case "":
+		c.float = dataType == "float" || dataType == "double"
+	case RawTypeInteger:
+	case RawTypeFloat:
+		c.float = true
+	default:
+		return nil, fmt.Errorf("rawType must be %s or %s instead of %q", RawTypeInteger, RawTypeFloat, config.RawType)
+	}
+	switch config.Width {
+	case 0, 1, 2, 4, 8:
+	default:
+		return nil, fmt.Errorf("width of a numeric value must be 1, 2, 4 or 8 instead of %d", config.Width)
+	}
+	if c.float && (config.Width == 1 || config.Width == 2) {
+		return nil, fmt.Errorf("width of a float raw value must be 4 or 8 instead of %d", config.Width)
+	}
+	if c.float && (config.Signed || config.BitLength != 0) {
+		return nil, fmt.Errorf("signed and bit field are for integer raw values")
+	}
+	if config.BitOffset < 0 || config.BitLength < 0 || config.BitOffset+config.BitLength > c.width(8)*8 {
+		return nil, fmt.Errorf("bit field at %d of %d bits is out of the %d bytes value",
+			config.BitOffset, config.BitLength, c.width(8))
+	}
+	if config.BitOffset > 0 && config.BitLength == 0 {
+		return nil, fmt.Errorf("bitOffset is set without bitLength")
+	}
+	return c, nil
+}
+
+// numeric return whether the values are decoded from numbers. Booleans are integers.
+func (c *Codec) numeric() bool {
+	return IsNumeric(c.dataType) || c.dataType == "boolean" || c.config.RawType != ""
+}
+
+// width return the width of the raw values, n if it is not set.
+func (c *Codec) width(n int) int {
+	if c.config.Width != 0 {
+		return c.config.Width
+	}
+	return n
+}
+
+// typeWidth return the number of bytes a value of the data type is encoded in by default.
+func (c *Codec) typeWidth() int {
+	switch c.dataType {
+	case "boolean", "int8", "uint8":
+		return 1
+	case "int16", "uint16":
+		return 2
+	case "int32", "uint32", "float":
+		return 4
+	}
+	return 8
+}
+
+// Decode decode the bytes read from the device into a value of the property.
+func (c *Codec) Decode(raw []byte) (string, error) {
+	width := c.width(len(raw))
+	if len(raw) < width {
+		return "", fmt.Errorf("decode %d bytes failed, expect %d bytes", len(raw), width)
+	}
+	data := append([]byte(nil), raw[:width]...)
+
+	if !c.numeric() {
+		switch c.dataType {
+		case "hex":
+			return hex.EncodeToString(data), nil
+		case "base64":
+			return base64.StdEncoding.EncodeToString(data), nil
+		}
+		return string(data), nil
+	}
+
+	switch width {
+	case 1, 2, 4, 8:
+	default:
+		return "", fmt.Errorf("decode %d bytes failed, a numeric value is 1, 2, 4 or 8 bytes", width)
+	}
+	if err := c.toBigEndian(data); err != nil {
+		return "", err
+	}
+	var bits uint64
+	for _, b := range data {
+		bits = bits<<8 | uint64(b)
+	}
+
+	var value float64
+	switch {
+	case c.float && width == 4:
+		value = float64(math.Float32frombits(uint32(bits)))
+	case c.float && width == 8:
+		value = math.Float64frombits(bits)
+	case c.float:
+		return "", fmt.Errorf("decode %d bytes as float failed, expect 4 or 8 bytes", width)
+	default:
+		size := width * 8
+		if c.config.BitLength > 0 {
+			bits = bits >> uint(c.config.BitOffset) & (1<<uint(c.config.BitLength) - 1)
+			size = c.config.BitLength
+		}
+		if c.config.Signed && size < 64 && bits&(1<<uint(size-1)) != 0 {
+			bits |= math.MaxUint64 << uint(size)
+		}
+		if c.config.Signed {
+			value = float64(int64(bits))
+		} else {
+			value = float64(bits)
+		}
+	}
+	value = value*c.config.Scale + c.config.Offset
+
+	if c.dataType == "boolean" {
+		return strconv.FormatBool(value != 0), nil
+	}
+	if !IsNumeric(c.dataType) {
+		return formatNumber("double", value, c.config.Scale != 1 || c.config.Offset != 0)
+	}
+	return formatNumber(c.dataType, value, c.config.Scale != 1 || c.config.Offset != 0)
+}
+
+// Encode encode a value of the property into the bytes written to the device. A bit field
+// is encoded with the other bits set to 0.
+func (c *Codec) Encode(value string) ([]byte, error) {
+	if !c.numeric() {
+		data := []byte(value)
+		var err error
+		switch c.dataType {
+		case "hex", "base64":
+			var v interface{}
+			if v, err = Convert(c.dataType, value); err != nil {
+				return nil, err
+			}
+			data = v.([]byte)
+		}
+		if c.config.Width == 0 {
+			return data, nil
+		}
+		if len(data) > c.config.Width {
+			return nil, fmt.Errorf("encode %q failed, it is longer than %d bytes", value, c.config.Width)
+		}
+		return append(data, make([]byte, c.config.Width-len(data))...), nil
+	}
+
+	var number float64
+	if c.dataType == "boolean" {
+		b, err := Convert(c.dataType, value)
+		if err != nil {
+			return nil, err
+		}
+		if b.(bool) {
+			number = 1
+		}
+	} else {
+		var err error
+		if number, err = strconv.ParseFloat(value, 64); err != nil {
+			return nil, convertError(c.dataType, value, "not a number")
+		}
+	}
+	number = (number - c.config.Offset) / c.config.Scale
+
+	width := c.width(c.typeWidth())
+	var bits uint64
+	switch {
+	case c.float && width == 4:
+		bits = uint64(math.Float32bits(float32(number)))
+	case c.float && width == 8:
+		bits = math.Float64bits(number)
+	case c.float:
+		return nil, fmt.Errorf("encode %s as float failed, width must be 4 or 8 bytes", value)
+	default:
+		size := width * 8
+		if c.config.BitLength > 0 {
+			size = c.config.BitLength
+		}
+		raw := math.Round(number)
+		var min, max float64
+		if c.config.Signed {
+			min, max = -math.Ldexp(1, size-1), math.Ldexp(1, size-1)-1
+		} else {
+			min, max = 0, math.Ldexp(1, size)-1
+		}
+		if raw < min || raw > max {
+			return nil, fmt.Errorf("encode %s failed, raw value %.0f is out of range [%.0f, %.0f]", value, raw, min, max)
+		}
+		if raw < 0 {
+			bits = uint64(int64(raw))
+		} else {
+			bits = uint64(raw)
+		}
+		if size < 64 {
+			bits &= 1<<uint(size) - 1
+		}
+		bits <<= uint(c.config.BitOffset)
+	}
+
+	data := make([]byte, width)
+	for i := width - 1; i >= 0; i-- {
+		data[i] = byte(bits)
+		bits >>= 8
+	}
+	if err := c.fromBigEndian(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// toBigEndian reorder the bytes of the device into big endian.
+func (c *Codec) toBigEndian(data []byte) error {
+	if c.config.WordSwap {
+		if len(data)%2 != 0 {
+			return fmt.Errorf("swap the words of %d bytes failed, the length is odd", len(data))
+		}
+		swapWords(data)
+	}
+	if c.config.ByteOrder == ByteOrderLittle {
+		reverse(data)
+	}
+	return nil
+}
+
+// fromBigEndian reorder big endian bytes into the order of the device.
+func (c *Codec) fromBigEndian(data []byte) error {
+	if c.config.ByteOrder == ByteOrderLittle {
+		reverse(data)
+	}
+	if c.config.WordSwap {
+		if len(data)%2 != 0 {
+			return fmt.Errorf("swap the words of %d bytes failed, the length is odd", len(data))
+		}
+		swapWords(data)
+	}
+	return nil
+}
+
+// reverse reverse the order of the bytes.
+func reverse(data []byte) {
+	for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+		data[i], data[j] = data[j], data[i]
+	}
+}
+
+// swapWords reverse the order of the 16 bits words, keeping the order of their bytes.
+func swapWords(data []byte) {
+	for i, j := 0, len(data)-2; i < j; i, j = i+2, j-2 {
+		data[i], data[j] = data[j], data[i]
+		data[i+1], data[j+1] = data[j+1], data[i+1]
+	}
+}
+
+// formatNumber format the number as a value of the numeric data type. An integer is
+// rounded and must be in the range of the type. If rounded is set, a float is rounded to
+// 9 decimals to drop the floating point noise of the computation which made it.
+func formatNumber(valueType string, value float64, rounded bool) (string, error) {
+	if IsInteger(valueType) {
+		s := strconv.FormatFloat(math.Round(value), 'f', -1, 64)
+		if _, err := Convert(valueType, s); err != nil {
+			return "", err
+		}
+		return s, nil
+	}
+	if rounded {
+		value = math.Round(value*1e9) / 1e9
+	}
+	if valueType == "float" {
+		return strconv.FormatFloat(value, 'f', -1, 32), nil
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64), nil
+}
@@ -0,0 +1,99 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodecDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		dataType string
+		config   CodecConfig
+		raw      []byte
+		value    string
+	}{
+		{"unsigned", "int", CodecConfig{}, []byte{0xff, 0xfe}, "65534"},
+		{"signed", "int", CodecConfig{Signed: true}, []byte{0xff, 0xfe}, "-2"},
+		{"little endian", "int", CodecConfig{ByteOrder: ByteOrderLittle}, []byte{0x01, 0x02}, "513"},
+		{"word swap", "int", CodecConfig{WordSwap: true}, []byte{0x00, 0x02, 0x00, 0x01}, "65538"},
+		{"little endian word swap", "int", CodecConfig{ByteOrder: ByteOrderLittle, WordSwap: true},
+			[]byte{0x01, 0x00, 0x02, 0x00}, "65538"},
+		{"scale and offset", "double", CodecConfig{RawType: RawTypeInteger, Signed: true, Scale: 0.1, Offset: -40},
+			[]byte{0x02, 0x9b}, "26.7"},
+		{"scaled int", "int", CodecConfig{Scale: 0.5}, []byte{0x05}, "3"},
+		{"bit field", "int", CodecConfig{BitOffset: 4, BitLength: 3}, []byte{0x00, 0x50}, "5"},
+		{"signed bit field", "int", CodecConfig{BitOffset: 4, BitLength: 3, Signed: true}, []byte{0x00, 0x70}, "-1"},
+		{"bit", "boolean", CodecConfig{BitOffset: 9, BitLength: 1}, []byte{0x02, 0x00}, "true"},
+		{"width", "int16", CodecConfig{Width: 2, Signed: true}, []byte{0x80, 0x00, 0xff}, "-32768"},
+		{"float", "float", CodecConfig{}, []byte{0x41, 0xac, 0x00, 0x00}, "21.5"},
+		{"double", "double", CodecConfig{ByteOrder: ByteOrderLittle}, []byte{0, 0, 0, 0, 0, 0, 0x35, 0x40}, "21"},
+		{"string", "string", CodecConfig{}, []byte("on"), "on"},
+		{"hex", "hex", CodecConfig{Width: 2}, []byte{0x0a, 0xff, 0x00}, "0aff"},
+	}
+	for _, test := range tests {
+		c, err := NewCodec(test.dataType, test.config)
+		assert.Nil(t, err, test.name)
+		value, err := c.Decode(test.raw)
+		assert.Nil(t, err, test.name)
+		assert.Equal(t, test.value, value, test.name)
+	}
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	tests := []struct {
+		dataType string
+		config   CodecConfig
+		value    string
+	}{
+		{"int", CodecConfig{Width: 2, Signed: true}, "-2"},
+		{"int32", CodecConfig{ByteOrder: ByteOrderLittle, WordSwap: true, Signed: true}, "-100000"},
+		{"double", CodecConfig{RawType: RawTypeInteger, Width: 2, Signed: true, Scale: 0.1, Offset: -40}, "26.7"},
+		{"uint8", CodecConfig{BitOffset: 4, BitLength: 4}, "9"},
+		{"float", CodecConfig{WordSwap: true}, "21.5"},
+		{"double", CodecConfig{}, "-0.001"},
+		{"boolean", CodecConfig{}, "true"},
+		{"string", CodecConfig{Width: 4}, "on\x00\x00"},
+		{"base64", CodecConfig{}, "CgD/"},
+	}
+	for _, test := range tests {
+		c, err := NewCodec(test.dataType, test.config)
+		assert.Nil(t, err, test.dataType)
+		raw, err := c.Encode(test.value)
+		assert.Nil(t, err, test.dataType)
+		value, err := c.Decode(raw)
+		assert.Nil(t, err, test.dataType)
+		assert.Equal(t, test.value, value, test.dataType)
+	}
+}
+
+func TestCodecErrors(t *testing.T) {
+	_, err := NewCodec("int", CodecConfig{Width: 3})
+	assert.EqualError(t, err, "width of a numeric value must be 1, 2, 4 or 8 instead of 3")
+	_, err = NewCodec("int", CodecConfig{Width: 1, BitOffset: 4, BitLength: 8})
+	assert.EqualError(t, err, "bit field at 4 of 8 bits is out of the 1 bytes value")
+	_, err = NewCodec("string", CodecConfig{Scale: 2})
+	assert.NotNil(t, err)
+	_, err = NewCodec("int", CodecConfig{ByteOrder: "middle"})
+	assert.NotNil(t, err)
+
+	c, err := NewCodec("int", CodecConfig{Width: 1})
+	assert.Nil(t, err)
+	_, err = c.Encode("256")
+	assert.EqualError(t, err, "encode 256 failed, raw value 256 is out of range [0, 255]")
+	_, err = c.Decode(nil)
+	assert.EqualError(t, err, "decode 0 bytes failed, expect 1 bytes")
+
+	c, err = NewCodec("int", CodecConfig{})
+	assert.Nil(t, err)
+	_, err = c.Decode([]byte{0x01, 0x02, 0x03})
+	assert.EqualError(t, err, "decode 3 bytes failed, a numeric value is 1, 2, 4 or 8 bytes")
+	_, err = c.Decode(make([]byte, 9))
+	assert.NotNil(t, err)
+
+	c, err = NewCodec("int8", CodecConfig{Width: 2})
+	assert.Nil(t, err)
+	_, err = c.Decode([]byte{0x01, 0x00})
+	assert.EqualError(t, err, `convert "256" to int8 failed: out of range [-128, 127]`)
+}
@@ -35,6 +35,22 @@ type DeviceInstance struct {
 	Twins            []Twin            `json:"twins,omitempty"`
 	Datas            Data              `json:"data,omitempty"`
 	PropertyVisitors []PropertyVisitor `json:"propertyVisitors,omitempty"`
+	Attributes       []Attribute       `json:"attributes,omitempty"`
+}
+
+// Attribute is a device attribute, such as the serial number or the firmware version,
+// published with the device state.
+type Attribute struct {
+	Name string `json:"name,omitempty"`
+	// Value is the value of a static attribute.
+	Value string `json:"value,omitempty"`
+	// Type is the type in the attribute metadata, string by default.
+	Type string `json:"type,omitempty"`
+	// VisitorConfig makes the attribute dynamic. It is read from the device by the driver,
+	// as the visitor config of a property.
+	VisitorConfig json.RawMessage `json:"visitorConfig,omitempty"`
+	// CollectCycle is in milliseconds, how often a dynamic attribute is read.
+	CollectCycle int64 `json:"collectCycle,omitempty"`
 }
 
 // DeviceModel is structure to store deviceModel in deviceProfile.json in configmap.
@@ -76,6 +92,52 @@ type PropertyVisitor struct {
 	VisitorConfig json.RawMessage `json:"visitorConfig"`
 }
 
+// ReportConfig is how the values of a property are reported. It is set in the configData
+// of the visitor config, next to the protocol configuration.
+type ReportConfig struct {
+	// Aggregation is how the samples collected during a report cycle are reported,
+	// one of the Aggregation constants. The default is the last sample.
+	Aggregation string `json:"aggregation,omitempty"`
+	// OnChange reports a value only if it differs from the last reported value.
+	OnChange bool `json:"onChange,omitempty"`
+	// Deadband reports a numeric value only if it differs from the last reported value
+	// by more than the deadband, DeadbandPercent by more than the percentage of it.
+	Deadband        float64 `json:"deadband,omitempty"`
+	DeadbandPercent float64 `json:"deadbandPercent,omitempty"`
+	// MaxSilence is in milliseconds. A value is reported if nothing was reported for
+	// longer, even if it didn't change.
+	MaxSilence int64 `json:"maxSilence,omitempty"`
+}
+
+// ValidateConfig constrains the values written to a property, in addition to the data
+// type and the range of the property. It is set in the configData of the visitor config.
+type ValidateConfig struct {
+	// Enum is the values allowed, any value if it is empty.
+	Enum []string `json:"enum,omitempty"`
+	// Pattern is a regular expression the whole value must match.
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// UnitConfig converts the values of a numeric property between the unit of the device
+// and the unit of the property in the device model. It is set in the configData of the
+// visitor config.
+type UnitConfig struct {
+	// DeviceUnit is the unit of the values read from and written to the device, the
+	// values are not converted if it is empty.
+	DeviceUnit string `json:"deviceUnit,omitempty"`
+}
+
+// ExpressionConfig transforms the values of a property with expressions, see Expression.
+// It is set in the configData of the visitor config.
+type ExpressionConfig struct {
+	// ReadExpression makes the property value of the value read from the device, which
+	// is the variable raw.
+	ReadExpression string `json:"readExpression,omitempty"`
+	// WriteExpression is the inverse of ReadExpression. It makes the value written to the
+	// device of the property value, which is the variable value.
+	WriteExpression string `json:"writeExpression,omitempty"`
+}
+
 // Data is data structure for the message that only be subscribed in edge node internal.
 type Data struct {
 	Properties []DataProperty `json:"dataProperties,omitempty"`
@@ -24,3 +24,21 @@ const (
 	DEVSTUNHEALTHY = "UNHEALTHY"    /* Unhealthy status from device */
 	DEVSTUNKNOWN   = "UNKNOWN"
 )
+
+// Property access mode definition.
+const (
+	AccessModeReadWrite = "ReadWrite"
+	AccessModeReadOnly  = "ReadOnly"
+	AccessModeWriteOnly = "WriteOnly"
+)
+
+// Aggregation definition of the samples reported once per report cycle.
+const (
+	AggregationLast  = "last"
+	AggregationMin   = "min"
+	AggregationMax   = "max"
+	AggregationMean  = "mean"
+	AggregationSum   = "sum"
+	AggregationCount = "count"
+	AggregationAll   = "all"
+)
@@ -17,24 +17,227 @@ limitations under the License.
 package common
 
 import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"math"
 	"strconv"
+	"strings"
+	"time"
 )
 
-// Convert string to other types
+// ErrUnknownType is returned by Convert for a data type it doesn't know.
+var ErrUnknownType = errors.New("unknown data type")
+
+// Enum is the value of an enum data type, such as "enum(off=0,on=1)".
+type Enum struct {
+	Label string
+	Value int64
+}
+
+// Convert string to other types. The value is returned as:
+//
+//	int, int64: int64, and int8 to int32, uint8 to uint64 as the Go type of the same name
+//	float: float32 range as float64; double: float64; boolean: bool; string: string
+//	bytes: []byte of the string; hex, base64: the decoded []byte
+//	timestamp: time.Time of a RFC 3339 timestamp
+//	enum(label=value,...): Enum of the label or the value
+//	json: any JSON value; object: map[string]interface{}; array: []interface{}
 func Convert(valueType string, value string) (result interface{}, err error) {
 	switch valueType {
-	case "int":
-		return strconv.ParseInt(value, 10, 64)
+	case "int", "int64":
+		return convertInt(valueType, value, 64)
+	case "int8":
+		v, err := convertInt(valueType, value, 8)
+		return int8(v), err
+	case "int16":
+		v, err := convertInt(valueType, value, 16)
+		return int16(v), err
+	case "int32":
+		v, err := convertInt(valueType, value, 32)
+		return int32(v), err
+	case "uint8":
+		v, err := convertUint(valueType, value, 8)
+		return uint8(v), err
+	case "uint16":
+		v, err := convertUint(valueType, value, 16)
+		return uint16(v), err
+	case "uint32":
+		v, err := convertUint(valueType, value, 32)
+		return uint32(v), err
+	case "uint64":
+		return convertUint(valueType, value, 64)
 	case "float":
-		return strconv.ParseFloat(value, 32)
+		v, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return v, convertError(valueType, value, numError(err, "out of the float range"))
+		}
+		return v, nil
 	case "double":
-		return strconv.ParseFloat(value, 64)
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return v, convertError(valueType, value, numError(err, "out of the double range"))
+		}
+		return v, nil
 	case "boolean":
-		return strconv.ParseBool(value)
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return v, convertError(valueType, value, "not true or false")
+		}
+		return v, nil
 	case "string":
 		return value, nil
-	default:
-		return nil, errors.New("Convert failed")
+	case "bytes":
+		return []byte(value), nil
+	case "hex":
+		v, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(value, "0x"), "0X"))
+		if err != nil {
+			return nil, convertError(valueType, value, err)
+		}
+		return v, nil
+	case "base64":
+		v, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, convertError(valueType, value, err)
+		}
+		return v, nil
+	case "timestamp":
+		v, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, convertError(valueType, value, "not a RFC 3339 timestamp")
+		}
+		return v, nil
+	case "json":
+		var v interface{}
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			return nil, convertError(valueType, value, err)
+		}
+		return v, nil
+	case "object":
+		var v map[string]interface{}
+		if err := json.Unmarshal([]byte(value), &v); err != nil || v == nil {
+			return nil, convertError(valueType, value, "not a JSON object")
+		}
+		return v, nil
+	case "array":
+		var v []interface{}
+		if err := json.Unmarshal([]byte(value), &v); err != nil || v == nil {
+			return nil, convertError(valueType, value, "not a JSON array")
+		}
+		return v, nil
+	}
+	if strings.HasPrefix(valueType, "enum(") {
+		return convertEnum(valueType, value)
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownType, valueType)
+}
+
+// CheckType return an error if Convert doesn't know the data type, or if it is an enum
+// type with invalid labels.
+func CheckType(valueType string) error {
+	if strings.HasPrefix(valueType, "enum(") {
+		_, err := parseEnum(valueType)
+		return err
+	}
+	if _, err := Convert(valueType, ""); errors.Is(err, ErrUnknownType) {
+		return err
+	}
+	return nil
+}
+
+// IsNumeric return whether the data type is a number.
+func IsNumeric(valueType string) bool {
+	switch valueType {
+	case "int", "int8", "int16", "int32", "int64",
+		"uint8", "uint16", "uint32", "uint64", "float", "double":
+		return true
+	}
+	return false
+}
+
+// IsInteger return whether the data type is an integer.
+func IsInteger(valueType string) bool {
+	return IsNumeric(valueType) && valueType != "float" && valueType != "double"
+}
+
+// convertError return the error of a value which can't be converted to the value type.
+func convertError(valueType string, value string, reason interface{}) error {
+	return fmt.Errorf("convert %q to %s failed: %v", value, valueType, reason)
+}
+
+// numError return the reason of a strconv error, with the range instead of the out of
+// range error.
+func numError(err error, outOfRange string) string {
+	var ne *strconv.NumError
+	if errors.As(err, &ne) {
+		if ne.Err == strconv.ErrRange {
+			return outOfRange
+		}
+		return "not a number"
+	}
+	return err.Error()
+}
+
+// convertInt parse the value as a signed integer of the bit size.
+func convertInt(valueType string, value string, bitSize int) (int64, error) {
+	v, err := strconv.ParseInt(value, 10, bitSize)
+	if err != nil {
+		max := int64(math.MaxInt64 >> (64 - bitSize))
+		return 0, convertError(valueType, value, numError(err, fmt.Sprintf("out of range [%d, %d]", -max-1, max)))
+	}
+	return v, nil
+}
+
+// convertUint parse the value as an unsigned integer of the bit size.
+func convertUint(valueType string, value string, bitSize int) (uint64, error) {
+	v, err := strconv.ParseUint(value, 10, bitSize)
+	if err != nil {
+		max := uint64(math.MaxUint64 >> (64 - bitSize))
+		if strings.HasPrefix(value, "-") {
+			if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+				return 0, convertError(valueType, value, fmt.Sprintf("out of range [0, %d]", max))
+			}
+		}
+		return 0, convertError(valueType, value, numError(err, fmt.Sprintf("out of range [0, %d]", max)))
+	}
+	return v, nil
+}
+
+// parseEnum parse the labels of an enum data type like "enum(off=0,on=1)".
+func parseEnum(valueType string) ([]Enum, error) {
+	if !strings.HasPrefix(valueType, "enum(") || !strings.HasSuffix(valueType, ")") {
+		return nil, fmt.Errorf("invalid enum type %q, expect enum(label=value,...)", valueType)
+	}
+	var labels []Enum
+	for _, item := range strings.Split(valueType[len("enum("):len(valueType)-1], ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid enum type %q, expect label=value instead of %q", valueType, item)
+		}
+		v, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid enum type %q, the value of %s is not an integer", valueType, parts[0])
+		}
+		labels = append(labels, Enum{Label: parts[0], Value: v})
+	}
+	return labels, nil
+}
+
+// convertEnum return the enum of the label or the value.
+func convertEnum(valueType string, value string) (Enum, error) {
+	labels, err := parseEnum(valueType)
+	if err != nil {
+		return Enum{}, err
+	}
+	v, numErr := strconv.ParseInt(value, 10, 64)
+	names := make([]string, 0, len(labels))
+	for _, e := range labels {
+		if e.Label == value || (numErr == nil && e.Value == v) {
+			return e, nil
+		}
+		names = append(names, e.Label)
 	}
+	return Enum{}, convertError(valueType, value, "not one of "+strings.Join(names, ", "))
 }
@@ -0,0 +1,92 @@
+package common
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvert(t *testing.T) {
+	v, err := Convert("int8", "-128")
+	assert.Nil(t, err)
+	assert.Equal(t, int8(-128), v)
+	_, err = Convert("int8", "128")
+	assert.EqualError(t, err, `convert "128" to int8 failed: out of range [-128, 127]`)
+	_, err = Convert("int32", "ten")
+	assert.EqualError(t, err, `convert "ten" to int32 failed: not a number`)
+
+	v, err = Convert("uint16", "65535")
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(65535), v)
+	_, err = Convert("uint16", "-1")
+	assert.EqualError(t, err, `convert "-1" to uint16 failed: out of range [0, 65535]`)
+	v, err = Convert("uint64", "18446744073709551615")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(18446744073709551615), v)
+
+	v, err = Convert("hex", "0x0aff")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x0a, 0xff}, v)
+	v, err = Convert("base64", "CgD/")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x0a, 0x00, 0xff}, v)
+	_, err = Convert("base64", "%%")
+	assert.NotNil(t, err)
+
+	v, err = Convert("timestamp", "2021-03-01T10:00:00.5+01:00")
+	assert.Nil(t, err)
+	assert.True(t, time.Date(2021, 3, 1, 9, 0, 0, 5e8, time.UTC).Equal(v.(time.Time)))
+	_, err = Convert("timestamp", "yesterday")
+	assert.EqualError(t, err, `convert "yesterday" to timestamp failed: not a RFC 3339 timestamp`)
+
+	v, err = Convert("object", `{"a":1}`)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"a": 1.0}, v)
+	_, err = Convert("object", `[1]`)
+	assert.EqualError(t, err, `convert "[1]" to object failed: not a JSON object`)
+	v, err = Convert("array", `[1,"a"]`)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{1.0, "a"}, v)
+
+	_, err = Convert("uuid", "1")
+	assert.True(t, errors.Is(err, ErrUnknownType))
+}
+
+func TestConvertEnum(t *testing.T) {
+	v, err := Convert("enum(off=0,on=1,auto=2)", "on")
+	assert.Nil(t, err)
+	assert.Equal(t, Enum{Label: "on", Value: 1}, v)
+	v, err = Convert("enum(off=0,on=1,auto=2)", "2")
+	assert.Nil(t, err)
+	assert.Equal(t, Enum{Label: "auto", Value: 2}, v)
+	_, err = Convert("enum(off=0,on=1,auto=2)", "eco")
+	assert.EqualError(t, err, `convert "eco" to enum(off=0,on=1,auto=2) failed: not one of off, on, auto`)
+	_, err = Convert("enum(off,on)", "on")
+	assert.NotNil(t, err)
+}
+
+func TestConvertUnit(t *testing.T) {
+	v, err := ConvertUnit(212, "°F", "°C")
+	assert.Nil(t, err)
+	assert.InDelta(t, 100, v, 1e-9)
+	v, err = ConvertUnit(100, "psi", "kPa")
+	assert.Nil(t, err)
+	assert.InDelta(t, 689.4757, v, 1e-4)
+	_, err = ConvertUnit(1, "°F", "kPa")
+	assert.EqualError(t, err, "can't convert °F to kPa, °F is a temperature unit and kPa a pressure unit")
+	_, err = ConvertUnit(1, "furlong", "m")
+	assert.EqualError(t, err, `unknown unit "furlong"`)
+
+	s, err := ConvertUnitValue("double", "212", "°F", "°C")
+	assert.Nil(t, err)
+	assert.Equal(t, "100", s)
+	s, err = ConvertUnitValue("int", "30", "psi", "kPa")
+	assert.Nil(t, err)
+	assert.Equal(t, "207", s)
+	_, err = ConvertUnitValue("int8", "30", "psi", "kPa")
+	assert.NotNil(t, err)
+	_, err = ConvertUnitValue("string", "30", "psi", "kPa")
+	assert.NotNil(t, err)
+}
@@ -20,9 +20,11 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"regexp"
+	"sync"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/google/uuid"
 )
 
 // Joint the topic like topic := fmt.Sprintf(TopicTwinUpdateDelta, deviceID)
@@ -31,6 +33,8 @@ const (
 	TopicTwinUpdate      = "$hw/events/device/%s/twin/update"
 	TopicStateUpdate     = "$hw/events/device/%s/state/update"
 	TopicDataUpdate      = "$ke/events/device/%s/data/update"
+	TopicTwinGet         = "$hw/events/device/%s/twin/get"
+	TopicTwinGetResult   = "$hw/events/device/%s/twin/get/result"
 )
 
 // MqttClient is parameters for Mqtt client.
@@ -43,6 +47,11 @@ type MqttClient struct {
 	Cert       string
 	PrivateKey string
 	Client     mqtt.Client
+
+	mu sync.Mutex
+	// onReconnect are called when the client connects again after the connection was lost.
+	onReconnect []func()
+	connected   bool
 }
 
 // newTLSConfig new TLS configuration.
@@ -73,6 +82,7 @@ func newTLSConfig(certfile string, privateKey string) (*tls.Config, error) {
 // Connect connect to the Mqtt server.
 func (mc *MqttClient) Connect() error {
 	opts := mqtt.NewClientOptions().AddBroker(mc.IP).SetClientID("").SetCleanSession(true)
+	opts.SetOnConnectHandler(mc.onConnect)
 	if mc.Cert != "" {
 		tlsConfig, err := newTLSConfig(mc.Cert, mc.PrivateKey)
 		if err != nil {
@@ -95,6 +105,31 @@ func (mc *MqttClient) Connect() error {
 	return nil
 }
 
+// OnReconnect register a function called when the client connects again after the connection
+// was lost. As the session is clean, the subscriptions are lost and must be made again.
+func (mc *MqttClient) OnReconnect(f func()) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.onReconnect = append(mc.onReconnect, f)
+}
+
+// onConnect call the reconnect functions, except on the first connection.
+func (mc *MqttClient) onConnect(client mqtt.Client) {
+	mc.mu.Lock()
+	reconnect := mc.connected
+	mc.connected = true
+	handlers := append([]func(){}, mc.onReconnect...)
+	mc.mu.Unlock()
+
+	if !reconnect {
+		return
+	}
+	for _, f := range handlers {
+		go f()
+	}
+}
+
 // Publish publish Mqtt message.
 func (mc *MqttClient) Publish(topic string, payload interface{}) error {
 	if tc := mc.Client.Publish(topic, mc.Qos, mc.Retained, payload); tc.Wait() && tc.Error() != nil {
@@ -111,16 +146,34 @@ func (mc *MqttClient) Subscribe(topic string, onMessage mqtt.MessageHandler) err
 	return nil
 }
 
+// Unsubscribe unsubscribe Mqtt topics.
+func (mc *MqttClient) Unsubscribe(topics ...string) error {
+	if tc := mc.Client.Unsubscribe(topics...); tc.Wait() && tc.Error() != nil {
+		return tc.Error()
+	}
+	return nil
+}
+
+// Disconnect disconnect from the Mqtt server after the pending messages are sent.
+func (mc *MqttClient) Disconnect() {
+	mc.Client.Disconnect(250)
+}
+
 // getTimestamp get current timestamp.
 func getTimestamp() int64 {
 	return time.Now().UnixNano() / 1e6
 }
 
+// NewBaseMessage create the base of a message with a new event ID and the current timestamp.
+func NewBaseMessage() BaseMessage {
+	return BaseMessage{EventID: uuid.New().String(), Timestamp: getTimestamp()}
+}
+
 // CreateMessageTwinUpdate create twin update message.
 func CreateMessageTwinUpdate(name string, valueType string, value string) (msg []byte, err error) {
 	var updateMsg DeviceTwinUpdate
 
-	updateMsg.BaseMessage.Timestamp = getTimestamp()
+	updateMsg.BaseMessage = NewBaseMessage()
 	updateMsg.Twin = map[string]*MsgTwin{}
 	updateMsg.Twin[name] = &MsgTwin{}
 	updateMsg.Twin[name].Actual = &TwinValue{Value: &value}
@@ -130,11 +183,65 @@ func CreateMessageTwinUpdate(name string, valueType string, value string) (msg [
 	return
 }
 
+// PropertyValue is a property value of a message with several properties.
+type PropertyValue struct {
+	Name  string
+	Type  string
+	Value string
+}
+
+// CreateMessageTwinUpdates create twin update message of several properties, which share
+// the timestamp of the message.
+func CreateMessageTwinUpdates(values []PropertyValue) (msg []byte, err error) {
+	var updateMsg DeviceTwinUpdate
+
+	updateMsg.BaseMessage = NewBaseMessage()
+	updateMsg.Twin = map[string]*MsgTwin{}
+	for i := range values {
+		updateMsg.Twin[values[i].Name] = &MsgTwin{Actual: &TwinValue{Value: &values[i].Value},
+			Metadata: &TypeMetadata{Type: values[i].Type}}
+	}
+
+	msg, err = json.Marshal(updateMsg)
+	return
+}
+
+// CreateMessageDatas create data message of several properties with the same timestamp.
+func CreateMessageDatas(values []PropertyValue) (msg []byte, err error) {
+	var dataMsg DeviceData
+
+	dataMsg.BaseMessage = NewBaseMessage()
+	dataMsg.Data = map[string]*DataValue{}
+	for _, value := range values {
+		dataMsg.Data[value.Name] = &DataValue{Value: value.Value,
+			Metadata: DataMetadata{Timestamp: dataMsg.BaseMessage.Timestamp, Type: value.Type}}
+	}
+
+	msg, err = json.Marshal(dataMsg)
+	return
+}
+
+// CreateMessageTwinError create twin update message of a desired value which was rejected.
+// The actual value is the last value read from the device, omitted if it is nil.
+func CreateMessageTwinError(name string, valueType string, value *string, reason string) (msg []byte, err error) {
+	var updateMsg DeviceTwinUpdate
+
+	updateMsg.BaseMessage = NewBaseMessage()
+	updateMsg.Twin = map[string]*MsgTwin{}
+	updateMsg.Twin[name] = &MsgTwin{}
+	updateMsg.Twin[name].Actual = &TwinValue{Value: value,
+		Metadata: ValueMetadata{Timestamp: getTimestamp(), Error: reason}}
+	updateMsg.Twin[name].Metadata = &TypeMetadata{Type: valueType}
+
+	msg, err = json.Marshal(updateMsg)
+	return
+}
+
 // CreateMessageData create data message.
 func CreateMessageData(name string, valueType string, value string) (msg []byte, err error) {
 	var dataMsg DeviceData
 
-	dataMsg.BaseMessage.Timestamp = getTimestamp()
+	dataMsg.BaseMessage = NewBaseMessage()
 	dataMsg.Data = map[string]*DataValue{}
 	dataMsg.Data[name] = &DataValue{}
 	dataMsg.Data[name].Value = value
@@ -145,17 +252,58 @@ func CreateMessageData(name string, valueType string, value string) (msg []byte,
 	return
 }
 
+// CreateMessageTwinGet create twin get message.
+func CreateMessageTwinGet() (msg []byte, err error) {
+	getMsg := NewBaseMessage()
+
+	msg, err = json.Marshal(getMsg)
+	return
+}
+
 // CreateMessageState create device status message.
 func CreateMessageState(state string) (msg []byte, err error) {
+	return CreateMessageDeviceUpdate(state, nil)
+}
+
+// CreateMessageDeviceUpdate create device status message with the attributes of the device.
+func CreateMessageDeviceUpdate(state string, attributes []PropertyValue) (msg []byte, err error) {
 	var stateMsg DeviceUpdate
 
-	stateMsg.BaseMessage.Timestamp = getTimestamp()
+	stateMsg.BaseMessage = NewBaseMessage()
 	stateMsg.State = state
+	if len(attributes) > 0 {
+		stateMsg.Attributes = map[string]*MsgAttr{}
+		for _, attr := range attributes {
+			stateMsg.Attributes[attr.Name] = &MsgAttr{Value: attr.Value,
+				Metadata: &TypeMetadata{Type: attr.Type}}
+		}
+	}
+
+	msg, err = json.Marshal(stateMsg)
+	return
+}
+
+// CreateMessageStateError create the ERROR device status message with the reason.
+func CreateMessageStateError(reason string) (msg []byte, err error) {
+	var stateMsg DeviceUpdate
+
+	stateMsg.BaseMessage = NewBaseMessage()
+	stateMsg.State = DEVSTERR
+	stateMsg.Reason = reason
 
 	msg, err = json.Marshal(stateMsg)
 	return
 }
 
+// GetTwinResultDeviceID extract the device ID from the twin get result topic.
+func GetTwinResultDeviceID(topic string) (id string) {
+	re := regexp.MustCompile(`hw/events/device/(.+)/twin/get/result`)
+	if match := re.FindStringSubmatch(topic); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
 // GetDeviceID extract the device ID from Mqtt topic.
 func GetDeviceID(topic string) (id string) {
 	re := regexp.MustCompile(`hw/events/device/(.+)/twin/update/delta`)
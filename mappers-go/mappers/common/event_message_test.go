@@ -0,0 +1,69 @@
+package common
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateMessageTwinUpdates(t *testing.T) {
+	msg, err := CreateMessageTwinUpdates([]PropertyValue{{Name: "temperature", Type: "int", Value: "21"},
+		{Name: "humidity", Type: "double", Value: "40.5"}})
+	assert.Nil(t, err)
+
+	var update DeviceTwinUpdate
+	assert.Nil(t, json.Unmarshal(msg, &update))
+	assert.Equal(t, 2, len(update.Twin))
+	assert.Equal(t, "21", *update.Twin["temperature"].Actual.Value)
+	assert.Equal(t, "double", update.Twin["humidity"].Metadata.Type)
+}
+
+func TestCreateMessageDatas(t *testing.T) {
+	msg, err := CreateMessageDatas([]PropertyValue{{Name: "temperature", Type: "int", Value: "21"},
+		{Name: "humidity", Type: "double", Value: "40.5"}})
+	assert.Nil(t, err)
+
+	var data DeviceData
+	assert.Nil(t, json.Unmarshal(msg, &data))
+	assert.Equal(t, 2, len(data.Data))
+	assert.Equal(t, "40.5", data.Data["humidity"].Value)
+	assert.Equal(t, data.Timestamp, data.Data["temperature"].Metadata.Timestamp)
+	assert.Equal(t, data.Timestamp, data.Data["humidity"].Metadata.Timestamp)
+}
+
+func TestEventID(t *testing.T) {
+	first, err := CreateMessageState(DEVSTOK)
+	assert.Nil(t, err)
+	second, err := CreateMessageTwinUpdate("temperature", "int", "21")
+	assert.Nil(t, err)
+
+	var state DeviceUpdate
+	var update DeviceTwinUpdate
+	assert.Nil(t, json.Unmarshal(first, &state))
+	assert.Nil(t, json.Unmarshal(second, &update))
+	assert.Len(t, state.EventID, 36)
+	assert.Len(t, update.EventID, 36)
+	assert.NotEqual(t, state.EventID, update.EventID)
+}
+
+func TestCreateMessageDeviceUpdate(t *testing.T) {
+	msg, err := CreateMessageDeviceUpdate(DEVSTOK, []PropertyValue{{Name: "firmware", Type: "string", Value: "1.0.2"}})
+	assert.Nil(t, err)
+
+	var update DeviceUpdate
+	assert.Nil(t, json.Unmarshal(msg, &update))
+	assert.Equal(t, DEVSTOK, update.State)
+	assert.Equal(t, "1.0.2", update.Attributes["firmware"].Value)
+	assert.Equal(t, "string", update.Attributes["firmware"].Metadata.Type)
+}
+
+func TestCreateMessageStateError(t *testing.T) {
+	msg, err := CreateMessageStateError(`protocol "mqtt" not found`)
+	assert.Nil(t, err)
+
+	var update DeviceUpdate
+	assert.Nil(t, json.Unmarshal(msg, &update))
+	assert.Equal(t, DEVSTERR, update.State)
+	assert.Equal(t, `protocol "mqtt" not found`, update.Reason)
+}
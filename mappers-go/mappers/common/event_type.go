@@ -31,6 +31,8 @@ type TwinValue struct {
 // ValueMetadata the meta of value.
 type ValueMetadata struct {
 	Timestamp int64 `json:"timestamp,omitempty"`
+	// Error tells why the desired value was not written to the device.
+	Error string `json:"error,omitempty"`
 }
 
 // TypeMetadata the meta of value type.
@@ -103,4 +105,6 @@ type DeviceUpdate struct {
 	BaseMessage
 	State      string              `json:"state,omitempty"`
 	Attributes map[string]*MsgAttr `json:"attributes"`
+	// Reason is why the device is in the ERROR state.
+	Reason string `json:"reason,omitempty"`
 }
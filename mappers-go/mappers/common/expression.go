@@ -0,0 +1,542 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Limits of the expressions, so a configmap can't make the mapper spend much on them.
+const (
+	maxExpressionLength = 1024
+	maxExpressionDepth  = 64
+)
+
+// Expression is a compiled expression transforming a property value, such as
+// `(raw - 4000) / 16000 * 100` or `raw > 0 ? "open" : "closed"`. It has numbers, strings
+// and booleans, the arithmetic, comparison and logical operators, the conditional operator
+// and the functions abs, ceil, floor, max, min, pow, round and sqrt. Its only input is one
+// variable, it can't reach anything else.
+type Expression struct {
+	text string
+	eval evalFunc
+}
+
+// evalFunc evaluate a node of an expression with the value of the variable.
+type evalFunc func(input interface{}) (interface{}, error)
+
+// CompileExpression compile the expression, in which the variable is the value transformed.
+func CompileExpression(text string, variable string) (*Expression, error) {
+	if len(text) > maxExpressionLength {
+		return nil, fmt.Errorf("expression is longer than %d characters", maxExpressionLength)
+	}
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %v", text, err)
+	}
+	p := &parser{text: text, tokens: tokens, variable: variable}
+	eval, err := p.parseExpression()
+	if err == nil && p.peek().kind != tokenEnd {
+		err = p.errorf("unexpected %s", p.peek())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %v", text, err)
+	}
+	return &Expression{text: text, eval: eval}, nil
+}
+
+// String return the text of the expression.
+func (e *Expression) String() string {
+	return e.text
+}
+
+// Evaluate evaluate the expression with the value, and format the result as a value of
+// the data type, with the booleans as 1 and 0 for the numeric types. The value is a number
+// or a boolean if it converts to one, and a string otherwise.
+func (e *Expression) Evaluate(value string, valueType string) (string, error) {
+	var input interface{} = value
+	if number, err := strconv.ParseFloat(value, 64); err == nil {
+		input = number
+	} else if b, err := strconv.ParseBool(value); err == nil {
+		input = b
+	}
+	result, err := e.eval(input)
+	if err != nil {
+		return "", fmt.Errorf("expression %q with %q: %v", e.text, value, err)
+	}
+
+	switch r := result.(type) {
+	case float64:
+		if math.IsNaN(r) || math.IsInf(r, 0) {
+			return "", fmt.Errorf("expression %q with %q: result is not a finite number", e.text, value)
+		}
+		if IsNumeric(valueType) {
+			s, err := formatNumber(valueType, r, true)
+			if err != nil {
+				return "", fmt.Errorf("expression %q with %q: %v", e.text, value, err)
+			}
+			return s, nil
+		}
+		if valueType == "boolean" {
+			return strconv.FormatBool(r != 0), nil
+		}
+		return strconv.FormatFloat(math.Round(r*1e9)/1e9, 'f', -1, 64), nil
+	case bool:
+		if IsNumeric(valueType) {
+			if r {
+				return "1", nil
+			}
+			return "0", nil
+		}
+		return strconv.FormatBool(r), nil
+	default:
+		return r.(string), nil
+	}
+}
+
+// Kinds of the tokens of an expression.
+const (
+	tokenEnd = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOperator
+)
+
+// token is a token of an expression at its byte position.
+type token struct {
+	kind   int
+	text   string
+	number float64
+	pos    int
+}
+
+// String describe the token in the errors.
+func (t token) String() string {
+	if t.kind == tokenEnd {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// operators are the operators of the expressions, the two characters ones first.
+var operators = []string{"<=", ">=", "==", "!=", "&&", "||",
+	"+", "-", "*", "/", "%", "<", ">", "!", "?", ":", "(", ")", ","}
+
+// tokenize split the expression into tokens.
+func tokenize(text string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c >= '0' && c <= '9' || c == '.':
+			j := i
+			for j < len(text) && (text[j] >= '0' && text[j] <= '9' || text[j] == '.' || text[j] == 'e' || text[j] == 'E' ||
+				(text[j] == '+' || text[j] == '-') && (text[j-1] == 'e' || text[j-1] == 'E')) {
+				j++
+			}
+			number, err := strconv.ParseFloat(text[i:j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at %d", text[i:j], i)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: text[i:j], number: number, pos: i})
+			i = j
+		case c == '"' || c == '\'':
+			j := i + 1
+			var b strings.Builder
+			for ; j < len(text) && text[j] != c; j++ {
+				if text[j] == '\\' && j+1 < len(text) {
+					j++
+				}
+				b.WriteByte(text[j])
+			}
+			if j >= len(text) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: b.String(), pos: i})
+			i = j + 1
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i
+			for j < len(text) && (text[j] == '_' || text[j] >= 'a' && text[j] <= 'z' ||
+				text[j] >= 'A' && text[j] <= 'Z' || text[j] >= '0' && text[j] <= '9') {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: text[i:j], pos: i})
+			i = j
+		default:
+			found := false
+			for _, op := range operators {
+				if strings.HasPrefix(text[i:], op) {
+					tokens = append(tokens, token{kind: tokenOperator, text: op, pos: i})
+					i += len(op)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("unexpected character %q at %d", c, i)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEnd, pos: len(text)}), nil
+}
+
+// parser is a recursive descent parser of the expressions, which compiles them into
+// closures.
+type parser struct {
+	text     string
+	tokens   []token
+	next     int
+	variable string
+	depth    int
+}
+
+// peek return the next token.
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+// accept consume the next token if it is one of the operators.
+func (p *parser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenOperator {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.next++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+// expect consume the next token, which must be the operator.
+func (p *parser) expect(op string) error {
+	if _, ok := p.accept(op); !ok {
+		return p.errorf("expect %q instead of %s", op, p.peek())
+	}
+	return nil
+}
+
+// errorf return an error at the position of the next token.
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s at %d", fmt.Sprintf(format, args...), p.peek().pos)
+}
+
+// parseExpression parse a conditional expression, the lowest precedence.
+func (p *parser) parseExpression() (evalFunc, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxExpressionDepth {
+		return nil, p.errorf("expression is nested deeper than %d", maxExpressionDepth)
+	}
+
+	cond, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.accept("?"); !ok {
+		return cond, nil
+	}
+	then, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	return func(input interface{}) (interface{}, error) {
+		c, err := cond(input)
+		if err != nil {
+			return nil, err
+		}
+		b, ok := c.(bool)
+		if !ok {
+			return nil, fmt.Errorf("condition is %s, not a boolean", describe(c))
+		}
+		if b {
+			return then(input)
+		}
+		return otherwise(input)
+	}, nil
+}
+
+// precedences are the binary operators by increasing precedence.
+var precedences = [][]string{{"||"}, {"&&"}, {"==", "!="}, {"<", "<=", ">", ">="}, {"+", "-"}, {"*", "/", "%"}}
+
+// parseBinary parse the binary operators of the precedence level and the higher ones.
+func (p *parser) parseBinary(level int) (evalFunc, error) {
+	if level == len(precedences) {
+		return p.parseUnary()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(precedences[level]...)
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = binary(op, left, right)
+	}
+}
+
+// parseUnary parse the unary operators.
+func (p *parser) parseUnary() (evalFunc, error) {
+	op, ok := p.accept("-", "!")
+	if !ok {
+		return p.parsePrimary()
+	}
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxExpressionDepth {
+		return nil, p.errorf("expression is nested deeper than %d", maxExpressionDepth)
+	}
+	operand, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return func(input interface{}) (interface{}, error) {
+		v, err := operand(input)
+		if err != nil {
+			return nil, err
+		}
+		if op == "!" {
+			b, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("operand of ! is %s, not a boolean", describe(v))
+			}
+			return !b, nil
+		}
+		n, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("operand of - is %s, not a number", describe(v))
+		}
+		return -n, nil
+	}, nil
+}
+
+// parsePrimary parse a literal, the variable, a function call or a parenthesized expression.
+func (p *parser) parsePrimary() (evalFunc, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenNumber:
+		p.next++
+		return constant(t.number), nil
+	case tokenString:
+		p.next++
+		return constant(t.text), nil
+	case tokenIdent:
+		p.next++
+		switch {
+		case t.text == "true" || t.text == "false":
+			return constant(t.text == "true"), nil
+		case t.text == p.variable:
+			return func(input interface{}) (interface{}, error) { return input, nil }, nil
+		}
+		if _, ok := p.accept("("); ok {
+			return p.parseCall(t)
+		}
+		return nil, fmt.Errorf("unknown variable %q at %d, the value is %s", t.text, t.pos, p.variable)
+	case tokenOperator:
+		if t.text == "(" {
+			p.next++
+			inner, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			return inner, p.expect(")")
+		}
+	}
+	return nil, p.errorf("unexpected %s", t)
+}
+
+// functions are the functions of the expressions by name, with their number of arguments.
+var functions = map[string]struct {
+	args int
+	call func(args []float64) float64
+}{
+	"abs":   {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"ceil":  {1, func(a []float64) float64 { return math.Ceil(a[0]) }},
+	"floor": {1, func(a []float64) float64 { return math.Floor(a[0]) }},
+	"round": {1, func(a []float64) float64 { return math.Round(a[0]) }},
+	"sqrt":  {1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"max":   {2, func(a []float64) float64 { return math.Max(a[0], a[1]) }},
+	"min":   {2, func(a []float64) float64 { return math.Min(a[0], a[1]) }},
+	"pow":   {2, func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+}
+
+// parseCall parse the arguments of a call of the function named by the token.
+func (p *parser) parseCall(name token) (evalFunc, error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at %d", name.text, name.pos)
+	}
+	var args []evalFunc
+	if _, ok := p.accept(")"); !ok {
+		for {
+			arg, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if _, ok := p.accept(","); !ok {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+	if len(args) != fn.args {
+		return nil, fmt.Errorf("function %s at %d takes %d arguments, not %d", name.text, name.pos, fn.args, len(args))
+	}
+	return func(input interface{}) (interface{}, error) {
+		values := make([]float64, len(args))
+		for i, arg := range args {
+			v, err := arg(input)
+			if err != nil {
+				return nil, err
+			}
+			n, ok := v.(float64)
+			if !ok {
+				return nil, fmt.Errorf("argument %d of %s is %s, not a number", i+1, name.text, describe(v))
+			}
+			values[i] = n
+		}
+		return fn.call(values), nil
+	}, nil
+}
+
+// constant return the node of a literal.
+func constant(v interface{}) evalFunc {
+	return func(interface{}) (interface{}, error) { return v, nil }
+}
+
+// binary return the node of a binary operator.
+func binary(op string, left evalFunc, right evalFunc) evalFunc {
+	return func(input interface{}) (interface{}, error) {
+		l, err := left(input)
+		if err != nil {
+			return nil, err
+		}
+		// The logical operators don't evaluate the right operand if they needn't.
+		if op == "&&" || op == "||" {
+			lb, ok := l.(bool)
+			if !ok {
+				return nil, fmt.Errorf("left operand of %s is %s, not a boolean", op, describe(l))
+			}
+			if lb == (op == "||") {
+				return lb, nil
+			}
+			r, err := right(input)
+			if err != nil {
+				return nil, err
+			}
+			rb, ok := r.(bool)
+			if !ok {
+				return nil, fmt.Errorf("right operand of %s is %s, not a boolean", op, describe(r))
+			}
+			return rb, nil
+		}
+		r, err := right(input)
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case "==":
+			return l == r, nil
+		case "!=":
+			return l != r, nil
+		}
+		if ls, ok := l.(string); ok {
+			if rs, ok := r.(string); ok {
+				switch op {
+				case "+":
+					return ls + rs, nil
+				case "<":
+					return ls < rs, nil
+				case "<=":
+					return ls <= rs, nil
+				case ">":
+					return ls > rs, nil
+				case ">=":
+					return ls >= rs, nil
+				}
+			}
+		}
+		ln, lok := l.(float64)
+		rn, rok := r.(float64)
+		if !lok || !rok {
+			return nil, fmt.Errorf("operands of %s are %s and %s, not numbers", op, describe(l), describe(r))
+		}
+		switch op {
+		case "+":
+			return ln + rn, nil
+		case "-":
+			return ln - rn, nil
+		case "*":
+			return ln * rn, nil
+		case "/":
+			if rn == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return ln / rn, nil
+		case "%":
+			if rn == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return math.Mod(ln, rn), nil
+		case "<":
+			return ln < rn, nil
+		case "<=":
+			return ln <= rn, nil
+		case ">":
+			return ln > rn, nil
+		default:
+			return ln >= rn, nil
+		}
+	}
+}
+
+// describe describe a value in the errors.
+func describe(v interface{}) string {
+	switch v := v.(type) {
+	case float64:
+		return fmt.Sprintf("the number %v", v)
+	case bool:
+		return fmt.Sprintf("the boolean %v", v)
+	default:
+		return fmt.Sprintf("the string %q", v)
+	}
+}
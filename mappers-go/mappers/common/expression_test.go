@@ -0,0 +1,85 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpression(t *testing.T) {
+	tests := []struct {
+		text      string
+		value     string
+		valueType string
+		result    string
+	}{
+		{"(raw - 4000) / 16000 * 100", "12000", "double", "50"},
+		{"(raw - 4000) / 16000 * 100", "4001", "int", "0"},
+		{`raw > 0 ? "open" : "closed"`, "1", "string", "open"},
+		{`raw > 0 ? "open" : "closed"`, "0", "string", "closed"},
+		{"raw * 0.1", "215", "float", "21.5"},
+		{"-raw + 2 * 3 % 4", "1", "int", "1"},
+		{"!raw", "true", "boolean", "false"},
+		{"raw >= 10 && raw <= 20 || raw == 42", "42", "boolean", "true"},
+		{`raw == "on" ? 1 : 0`, "on", "int", "1"},
+		{`"v" + raw`, "on", "string", "von"},
+		{"max(min(raw, 100), 0)", "120", "int", "100"},
+		{"round(sqrt(abs(raw)))", "-17", "int", "4"},
+		{"pow(2, raw) + floor(1.5) + ceil(0.2)", "3", "int", "10"},
+		{"raw > 5", "7", "int", "1"},
+		{"1.5e3", "", "double", "1500"},
+	}
+	for _, test := range tests {
+		e, err := CompileExpression(test.text, "raw")
+		assert.Nil(t, err, test.text)
+		if err != nil {
+			continue
+		}
+		result, err := e.Evaluate(test.value, test.valueType)
+		assert.Nil(t, err, test.text)
+		assert.Equal(t, test.result, result, test.text)
+	}
+}
+
+func TestExpressionErrors(t *testing.T) {
+	compile := []struct {
+		text string
+		err  string
+	}{
+		{"(raw - 4000", `expression "(raw - 4000": expect ")" instead of end of expression at 11`},
+		{"value * 2", `expression "value * 2": unknown variable "value" at 0, the value is raw`},
+		{"exec(raw)", `expression "exec(raw)": unknown function "exec" at 0`},
+		{"max(raw)", `expression "max(raw)": function max at 0 takes 2 arguments, not 1`},
+		{"raw ? 1", `expression "raw ? 1": expect ":" instead of end of expression at 7`},
+		{"raw 1", `expression "raw 1": unexpected "1" at 4`},
+		{`"open`, `expression "\"open": unterminated string at 0`},
+		{"raw # 2", `expression "raw # 2": unexpected character '#' at 4`},
+		{strings.Repeat("(", 100) + "raw" + strings.Repeat(")", 100), ""},
+		{strings.Repeat("raw+", 300) + "raw", "expression is longer than 1024 characters"},
+	}
+	for _, test := range compile {
+		_, err := CompileExpression(test.text, "raw")
+		assert.NotNil(t, err, test.text)
+		if test.err != "" && err != nil {
+			assert.EqualError(t, err, test.err)
+		}
+	}
+
+	e, err := CompileExpression("100 / raw", "raw")
+	assert.Nil(t, err)
+	_, err = e.Evaluate("0", "double")
+	assert.EqualError(t, err, `expression "100 / raw" with "0": division by zero`)
+	_, err = e.Evaluate("on", "double")
+	assert.EqualError(t, err, `expression "100 / raw" with "on": operands of / are the number 100 and the string "on", not numbers`)
+
+	e, err = CompileExpression("raw ? 1 : 0", "raw")
+	assert.Nil(t, err)
+	_, err = e.Evaluate("2", "int")
+	assert.EqualError(t, err, `expression "raw ? 1 : 0" with "2": condition is the number 2, not a boolean`)
+
+	e, err = CompileExpression("raw * 1000", "raw")
+	assert.Nil(t, err)
+	_, err = e.Evaluate("1", "int8")
+	assert.EqualError(t, err, `expression "raw * 1000" with "1": convert "1000" to int8 failed: out of range [-128, 127]`)
+}
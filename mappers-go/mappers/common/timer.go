@@ -25,19 +25,20 @@ type Timer struct {
 	Function func()
 	Duration time.Duration
 	Times    int
+	// Done stops the timer when it is closed. The timer never stops if it is nil.
+	Done <-chan struct{}
 }
 
 // Start start a timer.
 func (t *Timer) Start() {
 	ticker := time.NewTicker(t.Duration)
-	if t.Times > 0 {
-		for i := 0; i < t.Times; i++ {
-			<-ticker.C
-			t.Function()
-		}
-	} else {
-		for range ticker.C {
-			t.Function()
+	defer ticker.Stop()
+	for i := 0; t.Times <= 0 || i < t.Times; i++ {
+		select {
+		case <-t.Done:
+			return
+		case <-ticker.C:
 		}
+		t.Function()
 	}
 }
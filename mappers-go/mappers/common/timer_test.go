@@ -0,0 +1,32 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimerTimes(t *testing.T) {
+	count := 0
+	timer := Timer{Function: func() { count++ }, Duration: time.Millisecond, Times: 3}
+	timer.Start()
+	assert.Equal(t, 3, count)
+}
+
+func TestTimerDone(t *testing.T) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	timer := Timer{Function: func() {}, Duration: time.Millisecond, Done: done}
+	go func() {
+		timer.Start()
+		close(stopped)
+	}()
+
+	close(done)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("timer is not stopped")
+	}
+}
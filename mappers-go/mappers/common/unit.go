@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// unit is a unit of a quantity. A value in the unit is value*scale+offset in the base
+// unit of the quantity.
+type unit struct {
+	quantity string
+	scale    float64
+	offset   float64
+}
+
+// units are the units known by ConvertUnit, by their names.
+var units = map[string]unit{
+	// Temperature, in degree Celsius.
+	"°C":         {"temperature", 1, 0},
+	"C":          {"temperature", 1, 0},
+	"degC":       {"temperature", 1, 0},
+	"celsius":    {"temperature", 1, 0},
+	"°F":         {"temperature", 5.0 / 9, -32 * 5.0 / 9},
+	"F":          {"temperature", 5.0 / 9, -32 * 5.0 / 9},
+	"degF":       {"temperature", 5.0 / 9, -32 * 5.0 / 9},
+	"fahrenheit": {"temperature", 5.0 / 9, -32 * 5.0 / 9},
+	"K":          {"temperature", 1, -273.15},
+	"kelvin":     {"temperature", 1, -273.15},
+	// Pressure, in pascal.
+	"Pa":   {"pressure", 1, 0},
+	"hPa":  {"pressure", 100, 0},
+	"kPa":  {"pressure", 1000, 0},
+	"MPa":  {"pressure", 1e6, 0},
+	"mbar": {"pressure", 100, 0},
+	"bar":  {"pressure", 1e5, 0},
+	"psi":  {"pressure", 6894.757293168361, 0},
+	"atm":  {"pressure", 101325, 0},
+	// Length, in metre.
+	"mm": {"length", 0.001, 0},
+	"cm": {"length", 0.01, 0},
+	"m":  {"length", 1, 0},
+	"km": {"length", 1000, 0},
+	"in": {"length", 0.0254, 0},
+	"ft": {"length", 0.3048, 0},
+}
+
+// ConvertUnit convert the value from a unit to another of the same quantity, such as
+// from °F to °C or from psi to kPa.
+func ConvertUnit(value float64, from string, to string) (float64, error) {
+	if from == to {
+		return value, nil
+	}
+	f, ok := units[from]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", from)
+	}
+	t, ok := units[to]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", to)
+	}
+	if f.quantity != t.quantity {
+		return 0, fmt.Errorf("can't convert %s to %s, %s is a %s unit and %s a %s unit",
+			from, to, from, f.quantity, to, t.quantity)
+	}
+	return (value*f.scale + f.offset - t.offset) / t.scale, nil
+}
+
+// ConvertUnitValue convert the value of the numeric data type from a unit to another.
+// The result is rounded to an integer for the integer types, and to 9 decimals otherwise
+// to drop the floating point noise.
+func ConvertUnitValue(valueType string, value string, from string, to string) (string, error) {
+	if !IsNumeric(valueType) {
+		return "", fmt.Errorf("can't convert the unit of %s values", valueType)
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return "", convertError(valueType, value, "not a number")
+	}
+	result, err := ConvertUnit(v, from, to)
+	if err != nil {
+		return "", err
+	}
+	s, err := formatNumber(valueType, result, true)
+	if err != nil {
+		return "", fmt.Errorf("%v %s is %v %s: %v", value, from, result, to, err)
+	}
+	return s, nil
+}
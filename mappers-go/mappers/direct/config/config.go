@@ -29,6 +29,11 @@ import (
 type Config struct {
 	Mqtt      Mqtt   `yaml:"mqtt,omitempty"`
 	Configmap string `yaml:"configmap"`
+	HTTP      HTTP   `yaml:"http,omitempty"`
+	// ValidateOnly checks the configmap and exits instead of starting the mapper.
+	ValidateOnly bool `yaml:"-"`
+	// Strict refuses to start, or to reload, a configmap with any problem.
+	Strict bool `yaml:"-"`
 }
 
 // Mqtt is the Mqtt configuration.
@@ -40,6 +45,16 @@ type Mqtt struct {
 	PrivateKey    string `yaml:"privatekey,omitempty"`
 }
 
+// HTTP is the configuration of the embedded HTTP server. The server is disabled if
+// the address is empty, and the device API is disabled if the token is empty.
+// ReadyMaxDisconnected is the percentage of the devices which may be disconnected
+// while the mapper is ready, 0 to not check it.
+type HTTP struct {
+	Address              string `yaml:"address,omitempty"`
+	Token                string `yaml:"token,omitempty"`
+	ReadyMaxDisconnected int    `yaml:"readyMaxDisconnected,omitempty"`
+}
+
 // ErrConfigCert error of certification configuration.
 var ErrConfigCert = errors.New("Both certification and private key must be provided")
 
@@ -53,6 +68,8 @@ func (c *Config) Parse() error {
 
 	pflag.StringVar(&loglevel, "v", "1", "log level")
 	pflag.StringVar(&configFile, "config-file", defaultConfigFile, "Config file name")
+	pflag.BoolVar(&c.ValidateOnly, "validate-only", false, "check the configmap, print its problems and exit")
+	pflag.BoolVar(&c.Strict, "strict", false, "refuse a configmap with any problem instead of starting the valid devices")
 	pflag.Parse()
 	cf, err := ioutil.ReadFile(configFile)
 	if err != nil {
@@ -75,6 +92,10 @@ func (c *Config) parseFlags() error {
 	pflag.StringVar(&c.Mqtt.Password, "mqtt-password", c.Mqtt.Password, "password")
 	pflag.StringVar(&c.Mqtt.Cert, "mqtt-certification", c.Mqtt.Cert, "certification file path")
 	pflag.StringVar(&c.Mqtt.PrivateKey, "mqtt-priviatekey", c.Mqtt.PrivateKey, "private key file path")
+	pflag.StringVar(&c.HTTP.Address, "http-address", c.HTTP.Address, "HTTP server address")
+	pflag.StringVar(&c.HTTP.Token, "http-token", c.HTTP.Token, "bearer token of the device API")
+	pflag.IntVar(&c.HTTP.ReadyMaxDisconnected, "ready-max-disconnected", c.HTTP.ReadyMaxDisconnected,
+		"percentage of disconnected devices above which the mapper is not ready, 0 to not check it")
 	pflag.Parse()
 
 	if (c.Mqtt.Cert != "" && c.Mqtt.PrivateKey == "") ||
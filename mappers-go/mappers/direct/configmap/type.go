@@ -22,8 +22,27 @@ type DirectVisitorConfig struct {
 	VisitorConfigData `json:"configData"`
 }
 
+// VisitorConfigData tells how to find the property value in the device payload.
+// JSONPath, Regex and CSVIndex are tried in this order, TopicField is used if none is set.
 type VisitorConfigData struct {
 	TopicField string `json:"topicField,omitempty"`
+	// JSONPath is the path of the value in a JSON payload, like "env.t" or "$.values[0]".
+	JSONPath string `json:"jsonPath,omitempty"`
+	// Regex extracts the value from a text payload, the first capture group is the value.
+	Regex string `json:"regex,omitempty"`
+	// CSVIndex is the zero based column of the value in a CSV payload.
+	CSVIndex     *int   `json:"csvIndex,omitempty"`
+	CSVSeparator string `json:"csvSeparator,omitempty"`
+	// ReportTo is "twin" or "data". If empty, the value goes to twin if the
+	// property is a twin of the device, or to data if it is a data property.
+	ReportTo string `json:"reportTo,omitempty"`
+	// PayloadTemplate is a Go text/template rendering the command written to the device.
+	// The default command is {"<topicField>":"<value>"}.
+	PayloadTemplate string `json:"payloadTemplate,omitempty"`
+	// PayloadFormat is text, json, hex or base64. Rendered hex and base64 payloads are sent as raw bytes.
+	PayloadFormat string `json:"payloadFormat,omitempty"`
+	// OutputTopic overrides the output topic of the protocol for this property.
+	OutputTopic string `json:"outputTopic,omitempty"`
 }
 
 // DirectProtocolConfig is the protocol configuration.
@@ -40,6 +59,26 @@ type ConfigData struct {
 	Cert          string `json:"certification,omitempty"`
 	InputTopic    string `json:"inputTopic,omitempty"`
 	OutputTopic   string `json:"outputTopic,omitempty"`
+	// StatusTopic is the topic the device publishes its online/offline state
+	// and last-will message to.
+	StatusTopic    string `json:"statusTopic,omitempty"`
+	OnlinePayload  string `json:"onlinePayload,omitempty"`
+	OfflinePayload string `json:"offlinePayload,omitempty"`
+	// HeartbeatTimeout is in milliseconds. A device which publishes nothing
+	// for longer than the timeout is reported as disconnected.
+	HeartbeatTimeout int64 `json:"heartbeatTimeout,omitempty"`
+	// AckTopic is the topic the device acknowledges commands on. Each command carries
+	// a correlation ID which the device sends back in its acknowledgement.
+	AckTopic string `json:"ackTopic,omitempty"`
+	// AckTimeout is in milliseconds. If it is set, a write is confirmed by the
+	// acknowledgement or by the device reporting the written value.
+	AckTimeout int64 `json:"ackTimeout,omitempty"`
+	// AckRetries is how many times an unconfirmed command is sent again.
+	AckRetries int `json:"ackRetries,omitempty"`
+	// Onboarding makes this device the template of unknown devices. The input topic is
+	// subscribed with a wildcard, and a device which publishes with an unknown ID
+	// is registered as a copy of this device.
+	Onboarding bool `json:"onboarding,omitempty"`
 }
 
 // DirectProtocolCommonConfig is the direct protocol configuration.
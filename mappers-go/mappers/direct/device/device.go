@@ -17,10 +17,10 @@ limitations under the License.
 package device
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -32,314 +32,319 @@ import (
 	"github.com/kubeedge/mappers-go/mappers/direct/configmap"
 	"github.com/kubeedge/mappers-go/mappers/direct/driver"
 	"github.com/kubeedge/mappers-go/mappers/direct/globals"
+	"github.com/kubeedge/mappers-go/mappers/runtime"
 )
 
-var devices map[string]*globals.DirectDev
-var models map[string]common.DeviceModel
-var protocols map[string]common.Protocol
-var wg sync.WaitGroup
-
-// DeviceTwinDelta twin delta.
-/*type MqttTwinUpdate struct {
-	Delta map[string]string `json:"update"`
-}*/
-
-// setVisitor check if visitory property is readonly, if not then set it.
-func setVisitor(visitorConfig *configmap.DirectVisitorConfig, twin *common.Twin, client *driver.DirectClient) {
-	if twin.PVisitor.PProperty.AccessMode == "ReadOnly" {
-		klog.V(1).Info("Visit readonly property: ", client.Topic)
-		return
-	}
-
-	_, err := client.Set(visitorConfig.VisitorConfigData.TopicField, twin.Desired.Value)
-	if err != nil {
-		klog.Errorf("Set visitor error: %v %v", err, visitorConfig)
-		return
-	}
+// Driver is the direct MQTT driver of the mapper runtime. The devices publish
+// their properties, so they are not polled.
+type Driver struct {
+	// templates are the onboarding template devices by their input topic.
+	templates map[string]*runtime.Device
+	// onboarding are the IDs of the devices being onboarded, whose messages are dropped
+	// until they started.
+	onboarding  map[string]bool
+	templatesMu sync.Mutex
 }
 
-// getDeviceID extract the device ID from Mqtt topic.
-func getDeviceID(topic string) (id string) {
-	re := regexp.MustCompile(`hw/events/device/(.+)/twin/update/delta`)
-	return re.FindStringSubmatch(topic)[1]
-}
-
-// getDeviceID extract the device ID from Mqtt topic.
-func getTwinDeviceID(topic string) (id string) {
-	re := regexp.MustCompile(`mqtt/input/device/(.+)/delta`)
-	return re.FindStringSubmatch(topic)[1]
-}
-
-// onMessage callback function of Mqtt subscribe message.
-func onMessage(client mqtt.Client, message mqtt.Message) {
-	klog.V(2).Info("Receive message", message.Topic())
-	// Get device ID and get device instance
-	id := getDeviceID(message.Topic())
-	if id == "" {
-		klog.Error("Wrong topic")
-		return
-	}
-	klog.V(2).Info("Device id: ", id)
+var mapper = runtime.NewMapper(&Driver{}, &globals.MqttClient)
 
-	var dev *globals.DirectDev
-	var ok bool
-	if dev, ok = devices[id]; !ok {
-		klog.Error("Device not exist")
-		return
+// formatTopic replace the %s in the topic by the device ID.
+func formatTopic(topic string, deviceID string) string {
+	if !strings.Contains(topic, "%s") {
+		return topic
 	}
+	return fmt.Sprintf(topic, deviceID)
+}
 
-	// Get twin map key as the propertyName
-	var delta common.DeviceTwinDelta
-	if err := json.Unmarshal(message.Payload(), &delta); err != nil {
-		klog.Errorf("Unmarshal message failed: %v", err)
-		return
-	}
-	for twinName, twinValue := range delta.Delta {
-		i := 0
-		for i = 0; i < len(dev.Instance.Twins); i++ {
-			if twinName == dev.Instance.Twins[i].PropertyName {
-				break
-			}
-		}
-		if i == len(dev.Instance.Twins) {
-			klog.Error("Twin not found: ", twinName)
+// getTwinDeviceID extract the device ID from the Mqtt topic by the segment of the input
+// topic where it is, "%s" or "+". It returns false if the topic doesn't match.
+func getTwinDeviceID(inputTopic string, topic string) (string, bool) {
+	for _, wildcard := range []string{"%s", "+"} {
+		i := strings.Index(inputTopic, wildcard)
+		if i < 0 {
 			continue
 		}
-		// Desired value is not changed.
-		if dev.Instance.Twins[i].Desired.Value == twinValue {
-			continue
+		prefix, suffix := inputTopic[:i], inputTopic[i+len(wildcard):]
+		if len(topic) <= len(prefix)+len(suffix) ||
+			!strings.HasPrefix(topic, prefix) || !strings.HasSuffix(topic, suffix) {
+			return "", false
 		}
-		dev.Instance.Twins[i].Desired.Value = twinValue
-		var visitorConfig configmap.DirectVisitorConfig
-		if err := json.Unmarshal([]byte(dev.Instance.Twins[i].PVisitor.VisitorConfig), &visitorConfig); err != nil {
-			klog.Errorf("Unmarshal visitor config failed: %v", err)
-			continue
+		id := topic[len(prefix) : len(topic)-len(suffix)]
+		if strings.Contains(id, "/") {
+			return "", false
 		}
-		setVisitor(&visitorConfig, &dev.Instance.Twins[i], dev.DirectClient)
+		return id, true
 	}
+	return "", false
 }
 
-// onMessage callback function of Mqtt subscribe message.
-func onTwinMessage(client mqtt.Client, message mqtt.Message) {
-	var err error
-	klog.V(1).Info("Receive message", message.Topic())
-	// Get device ID and get device instance
-	id := getTwinDeviceID(message.Topic())
-	if id == "" {
-		klog.Error("Wrong topic")
-		return
-	}
-	klog.V(1).Info("Device id: ", id)
-
-	var dev *globals.DirectDev
-	var ok bool
-	if dev, ok = devices[id]; !ok {
-		klog.Error("Device not exist")
-		return
-	}
-
-	var delta map[string]string
-	if err := json.Unmarshal(message.Payload(), &delta); err != nil {
-		klog.Errorf("Unmarshal message failed: %v", err)
-		return
-	}
-	// Get twin map key as the propertyName
-	/*var delta MqttTwinUpdate
-	if err := json.Unmarshal(message.Payload(), &delta); err != nil {
-		klog.Errorf("Unmarshal message failed: %v", err)
-		return
-	}*/
-	for twinName, twinValue := range delta {
-		i := 0
-		//dev.DirectClient.Set(name, value)
-
-		for i = 0; i < len(dev.Instance.Twins); i++ {
-			if twinName == dev.Instance.Twins[i].PropertyName {
-				break
-			}
-		}
-		if i == len(dev.Instance.Twins) {
-			klog.Error("Twin not found: ", twinName)
-			continue
-		}
-		topic := fmt.Sprintf(common.TopicTwinUpdate, dev.Instance.ID)
-		// construct payload
-		var payload []byte
-		if payload, err = common.CreateMessageTwinUpdate(twinName, "string", twinValue); err != nil {
-			klog.Error("Create message twin update failed")
-			return
-		}
-
-		if err := globals.MqttClient.Publish(topic, payload); err != nil {
-			klog.Errorf("Publish topic %v failed, err: %v", topic, err)
-		}
-		klog.V(1).Infof("Update the %s value as %s", twinName, twinValue)
+// ParseVisitor parse the payload visitor of the property.
+func (d *Driver) ParseVisitor(dev *runtime.Device, prop *runtime.Property) error {
+	pv, err := newPayloadVisitor(prop)
+	if err != nil {
+		return err
 	}
+	prop.Config = pv
+	return nil
 }
 
 // initDirect initialize direct client
 func initDirect(protocolConfig configmap.DirectProtocolConfig, instanceID string) (client *driver.DirectClient, err error) {
 	if protocolConfig.MQTTConfigData.ServerAddress != "" {
 		directConfig := driver.DirectConfig{
-			ServerAddress: protocolConfig.MQTTConfigData.ServerAddress,
-			Username:      protocolConfig.MQTTConfigData.Username,
-			Password:      protocolConfig.MQTTConfigData.Password,
-			Cert:          protocolConfig.MQTTConfigData.Cert,
-			Topic:         fmt.Sprintf(protocolConfig.MQTTConfigData.OutputTopic, instanceID)}
+			ServerAddress:    protocolConfig.MQTTConfigData.ServerAddress,
+			Username:         protocolConfig.MQTTConfigData.Username,
+			Password:         protocolConfig.MQTTConfigData.Password,
+			Cert:             protocolConfig.MQTTConfigData.Cert,
+			Topic:            fmt.Sprintf(protocolConfig.MQTTConfigData.OutputTopic, instanceID),
+			InputTopic:       protocolConfig.MQTTConfigData.InputTopic,
+			OnlinePayload:    protocolConfig.MQTTConfigData.OnlinePayload,
+			OfflinePayload:   protocolConfig.MQTTConfigData.OfflinePayload,
+			HeartbeatTimeout: time.Duration(protocolConfig.MQTTConfigData.HeartbeatTimeout) * time.Millisecond,
+			AckTimeout:       time.Duration(protocolConfig.MQTTConfigData.AckTimeout) * time.Millisecond,
+			AckRetries:       protocolConfig.MQTTConfigData.AckRetries}
+		if protocolConfig.MQTTConfigData.StatusTopic != "" {
+			directConfig.StatusTopic = formatTopic(protocolConfig.MQTTConfigData.StatusTopic, instanceID)
+		}
+		if protocolConfig.MQTTConfigData.AckTopic != "" {
+			directConfig.AckTopic = formatTopic(protocolConfig.MQTTConfigData.AckTopic, instanceID)
+		}
 		client, err = driver.NewClient(directConfig)
 
 	} else {
 		return nil, errors.New("No protocol found")
 	}
 
-	return client, nil
+	return client, err
 }
 
-// initTwin initialize the timer to get twin value.
-/*func initTwin(dev *globals.DirectDev) {
-	for i := 0; i < len(dev.Instance.Twins); i++ {
-		var visitorConfig configmap.DirectVisitorConfig
-		if err := json.Unmarshal([]byte(dev.Instance.Twins[i].PVisitor.VisitorConfig), &visitorConfig); err != nil {
-			klog.Errorf("Unmarshal VisitorConfig error: %v", err)
-			continue
-		}
-		setVisitor(&visitorConfig, &dev.Instance.Twins[i], dev.DirectClient)
-
-		twinData := TwinData{Client: dev.DirectClient,
-			Name:          dev.Instance.Twins[i].PropertyName,
-			Type:          dev.Instance.Twins[i].Desired.Metadatas.Type,
-			VisitorConfig: &visitorConfig,
-			Topic:         fmt.Sprintf(common.TopicTwinUpdate, dev.Instance.ID)}
-		collectCycle := time.Duration(dev.Instance.Twins[i].PVisitor.CollectCycle)
-		// If the collect cycle is not set, set it to 1 second.
-		if collectCycle == 0 {
-			collectCycle = 1 * time.Second
-		}
-		timer := common.Timer{Function: twinData.Run, Duration: collectCycle, Times: 0}
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			timer.Start()
-		}()
+// Connect create the direct client of the device.
+func (d *Driver) Connect(dev *runtime.Device) error {
+	if !strings.Contains(dev.Instance.ProtocolName, "customized-protocol-mqtt-device") {
+		return fmt.Errorf("protocol not supported: %v", dev.Instance.ProtocolName)
+	}
+	var protocolCommConfig configmap.DirectProtocolCommonConfig
+	if err := json.Unmarshal([]byte(dev.Instance.PProtocol.ProtocolCommonConfig), &protocolCommConfig); err != nil {
+		return fmt.Errorf("unmarshal ProtocolCommonConfig error: %v", err)
+	}
+
+	var protocolConfig configmap.DirectProtocolConfig
+	if err := json.Unmarshal([]byte(dev.Instance.PProtocol.ProtocolConfigs), &protocolConfig); err != nil {
+		return fmt.Errorf("unmarshal ProtocolConfigs error: %v", err)
+	}
+
+	client, err := initDirect(protocolConfig, dev.Instance.ID)
+	if err != nil {
+		return err
 	}
+	dev.Client = client
+	return nil
 }
 
-// initData initialize the timer to get data.
-func initData(dev *globals.DirectDev) {
-	for i := 0; i < len(dev.Instance.Datas.Properties); i++ {
-		var visitorConfig configmap.DirectVisitorConfig
-		if err := json.Unmarshal([]byte(dev.Instance.Datas.Properties[i].PVisitor.VisitorConfig), &visitorConfig); err != nil {
-			klog.Errorf("Unmarshal VisitorConfig error: %v", err)
-			continue
+// Subscribe subscribe the input, status and ack topics of the device.
+// The input topic of an onboarding template is subscribed once for all its devices.
+func (d *Driver) Subscribe(dev *runtime.Device) error {
+	// The devices may have changed since the last device started, by a configmap reload.
+	d.initOnboarding(dev.Mapper().Devices())
+
+	client := dev.Client.(*driver.DirectClient)
+	directConfig := client.Config.(driver.DirectConfig)
+	onMessage := func(c mqtt.Client, message mqtt.Message) {
+		d.onTwinMessage(dev.Mapper(), directConfig.InputTopic, message)
+	}
+	if template := d.template(directConfig.InputTopic); template == nil {
+		if err := initTwinMqtt(directConfig.InputTopic, dev.Instance.ID, onMessage); err != nil {
+			return err
 		}
-		twinData := TwinData{Client: dev.DirectClient,
-			Name:          dev.Instance.Datas.Properties[i].PropertyName,
-			Type:          dev.Instance.Datas.Properties[i].Metadatas.Type,
-			VisitorConfig: &visitorConfig,
-			Topic:         fmt.Sprintf(common.TopicDataUpdate, dev.Instance.ID)}
-		collectCycle := time.Duration(dev.Instance.Datas.Properties[i].PVisitor.CollectCycle)
-		// If the collect cycle is not set, set it to 1 second.
-		if collectCycle == 0 {
-			collectCycle = 1 * time.Second
+	} else if template == dev {
+		if err := initTwinMqtt(directConfig.InputTopic, "+", onMessage); err != nil {
+			return err
 		}
-		timer := common.Timer{Function: twinData.Run, Duration: collectCycle, Times: 0}
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			timer.Start()
-		}()
 	}
-}*/
+
+	if err := initStatusMqtt(dev, client); err != nil {
+		return fmt.Errorf("subscribe status error: %v", err)
+	}
+	if err := client.SubscribeAck(); err != nil {
+		return fmt.Errorf("subscribe ack error: %v", err)
+	}
+	return nil
+}
 
 // initTwinMqtt subscribe Mqtt topics from outer device.
-func initTwinMqtt(deviceTopic string, instanceID string) error {
+func initTwinMqtt(deviceTopic string, instanceID string, onMessage mqtt.MessageHandler) error {
 	topic := fmt.Sprintf(deviceTopic, instanceID)
 	klog.V(1).Info("Subscribe topic: ", topic)
-	return globals.MqttClient.Subscribe(topic, onTwinMessage)
+	return globals.MqttClient.Subscribe(topic, onMessage)
 }
 
-// initSubscribeMqtt subscribe Mqtt topics from cloudcore.
-func initSubscribeMqtt(instanceID string) error {
-	var topic string
-	if globals.LocalTest {
-		topic = fmt.Sprintf("hw/events/device/%s/twin/update/delta", instanceID)
-	} else {
-		topic = fmt.Sprintf(common.TopicTwinUpdateDelta, instanceID)
-	}
-	klog.V(1).Info("Subscribe topic: ", topic)
-	return globals.MqttClient.Subscribe(topic, onMessage)
+// initStatusMqtt subscribe the status topic the device publishes its online/offline
+// and last-will payload to. A change of the device status is published at once.
+func initStatusMqtt(dev *runtime.Device, client *driver.DirectClient) error {
+	return client.SubscribeStatus(func(c mqtt.Client, message mqtt.Message) {
+		klog.V(2).Infof("Receive status message %s on topic %v", message.Payload(), message.Topic())
+		status := client.GetStatus()
+		client.UpdateLiveness(message.Payload())
+		if client.GetStatus() != status {
+			dev.PublishState()
+		}
+	})
 }
 
-// initGetStatus start timer to get device status and send to eventbus.
-func initGetStatus(dev *globals.DirectDev) {
-	getStatus := GetStatus{Client: dev.DirectClient,
-		topic: fmt.Sprintf(common.TopicStateUpdate, dev.Instance.ID)}
-	timer := common.Timer{Function: getStatus.Run, Duration: 1 * time.Second, Times: 0}
-	wg.Add(1)
+// onTwinMessage callback function of the properties the device publishes on the input topic.
+func (d *Driver) onTwinMessage(mapper *runtime.Mapper, inputTopic string, message mqtt.Message) {
+	klog.V(1).Info("Receive message", message.Topic())
+	// Get device ID and get device instance
+	id, ok := getTwinDeviceID(inputTopic, message.Topic())
+	if !ok {
+		klog.Errorf("Wrong topic %v, it doesn't match the input topic %v", message.Topic(), inputTopic)
+		return
+	}
+	klog.V(1).Info("Device id: ", id)
+
+	dev, ok := mapper.Device(id)
+	if ok {
+		d.reportMessage(dev, message)
+		return
+	}
+	template := d.findTemplate(message.Topic(), id)
+	if template == nil {
+		klog.Error("Device not exist")
+		return
+	}
+	if !d.startOnboarding(id) {
+		klog.V(2).Infof("Drop the message of %v, it is being onboarded", id)
+		return
+	}
+	// Starting the device subscribes and publishes, which must not block the Mqtt handler.
 	go func() {
-		defer wg.Done()
-		timer.Start()
+		defer d.endOnboarding(id)
+		d.reportMessage(onboard(mapper, template, id, message.Topic()), message)
 	}()
 }
 
-// start start the device.
-func start(dev *globals.DirectDev) {
-	if strings.Contains(dev.Instance.ProtocolName, "customized-protocol-mqtt-device") == false {
-		klog.Errorf("%v start fail, protocol not supported: %v", dev.Instance.ID, dev.Instance.ProtocolName)
+// reportMessage report the properties of the message the device published.
+func (d *Driver) reportMessage(dev *runtime.Device, message mqtt.Message) {
+	if !dev.Started() {
+		klog.V(2).Infof("Drop the message of %v, it isn't started", dev.Instance.ID)
 		return
 	}
-	var protocolCommConfig configmap.DirectProtocolCommonConfig
-	if err := json.Unmarshal([]byte(dev.Instance.PProtocol.ProtocolCommonConfig), &protocolCommConfig); err != nil {
-		klog.Errorf("Unmarshal ProtocolCommonConfig error: %v", err)
+	client, ok := dev.Client.(*driver.DirectClient)
+	if !ok {
 		return
 	}
+	client.Heartbeat()
 
-	var protocolConfig configmap.DirectProtocolConfig
-	if err := json.Unmarshal([]byte(dev.Instance.PProtocol.ProtocolConfigs), &protocolConfig); err != nil {
-		klog.Errorf("Unmarshal ProtocolConfigs error: %v", err)
-		return
+	p := &payload{raw: message.Payload()}
+	for i := 0; i < len(dev.Instance.PropertyVisitors); i++ {
+		prop, ok := dev.Properties[dev.Instance.PropertyVisitors[i].PropertyName]
+		if !ok || prop.ReportTo == "" {
+			continue
+		}
+		pv := prop.Config.(*payloadVisitor)
+		value, found, err := pv.extract(p)
+		if err != nil {
+			klog.Errorf("Extract %s from message failed: %v", pv.Name, err)
+			continue
+		}
+		if !found {
+			continue
+		}
+		client.Observe(pv.Name, value)
+		if err = dev.Report(prop, value); err != nil {
+			klog.Error(err)
+		}
 	}
+}
 
-	dev.Topic = protocolConfig.MQTTConfigData.InputTopic //save topic by device
-	//client, err := initDirect(protocolCommConfig, protocolConfig.SlaveID)
-	client, err := initDirect(protocolConfig, dev.Instance.ID)
-	if err != nil {
-		klog.Errorf("Init error: %v", err)
-		return
+// ReadProperty return the last value the device published for the property.
+func (d *Driver) ReadProperty(dev *runtime.Device, prop *runtime.Property) (string, error) {
+	client := dev.Client.(*driver.DirectClient)
+	value, ok := client.Value(prop.Name)
+	if !ok {
+		return "", fmt.Errorf("no value of %s was reported", prop.Name)
 	}
-	dev.DirectClient = client
+	return value, nil
+}
 
-	//initTwin(dev)
-	//initData(dev)
+// WriteProperty publish the command of the property to the device.
+func (d *Driver) WriteProperty(dev *runtime.Device, prop *runtime.Property, value string) error {
+	client := dev.Client.(*driver.DirectClient)
+	pv := prop.Config.(*payloadVisitor)
+	cmd := driver.Command{Name: prop.Name,
+		TopicField: pv.Config.TopicField,
+		Value:      value,
+		Template:   pv.Config.PayloadTemplate,
+		Format:     pv.Config.PayloadFormat,
+		DeviceID:   dev.Instance.ID}
+	if typedValue, err := common.Convert(prop.DataType, value); err == nil {
+		cmd.TypedValue = typedValue
+	}
+	if pv.Config.OutputTopic != "" {
+		cmd.Topic = formatTopic(pv.Config.OutputTopic, dev.Instance.ID)
+	}
+	_, err := client.Set(dev.Context(), cmd)
+	if err != nil && dev.Context().Err() == nil {
+		// The failure is reported in the twin of the property, not in the device status.
+		return fmt.Errorf("%w: %v", runtime.ErrNotApplied, err)
+	}
+	return err
+}
 
-	if err := initTwinMqtt(dev.Topic, dev.Instance.ID); err != nil {
-		klog.Errorf("Init subscribe mqtt error: %v", err)
-		return
+// GetStatus get the status of the device.
+func (d *Driver) GetStatus(dev *runtime.Device) string {
+	client, ok := dev.Client.(*driver.DirectClient)
+	if !ok {
+		return common.DEVSTUNKNOWN
 	}
+	return client.GetStatus()
+}
 
-	if err := initSubscribeMqtt(dev.Instance.ID); err != nil {
-		klog.Errorf("Init subscribe mqtt error: %v", err)
-		return
+// Close unsubscribe the topics of the device and release the direct client of it.
+// The input topic of an onboarding template is kept for the devices onboarded from it.
+func (d *Driver) Close(dev *runtime.Device) error {
+	client, ok := dev.Client.(*driver.DirectClient)
+	if !ok {
+		return nil
+	}
+	directConfig := client.Config.(driver.DirectConfig)
+	if d.template(directConfig.InputTopic) == nil {
+		if err := globals.MqttClient.Unsubscribe(fmt.Sprintf(directConfig.InputTopic, dev.Instance.ID)); err != nil {
+			klog.Errorf("Unsubscribe input topic of %v error: %v", dev.Instance.ID, err)
+		}
+	}
+	if err := client.Unsubscribe(); err != nil {
+		klog.Errorf("Unsubscribe status topic of %v error: %v", dev.Instance.ID, err)
 	}
+	return client.Close()
+}
 
-	klog.V(1).Info(dev.Instance.ID, " start successfully")
+// ProtocolSchema return the types of the protocol configs, to validate the device profile.
+func (d *Driver) ProtocolSchema() (interface{}, interface{}) {
+	return &configmap.DirectProtocolConfig{}, &configmap.DirectProtocolCommonConfig{}
+}
 
-	initGetStatus(dev)
+// VisitorSchema return the type of the visitor configs, to validate the device profile.
+func (d *Driver) VisitorSchema() interface{} {
+	return &configmap.DirectVisitorConfig{}
 }
 
 // DevInit initialize the device datas.
 func DevInit(configmapPath string) error {
-	devices = make(map[string]*globals.DirectDev)
-	models = make(map[string]common.DeviceModel)
-	protocols = make(map[string]common.Protocol)
-	return configmap.Parse(configmapPath, devices, models, protocols)
+	mapper.LocalTest = globals.LocalTest
+	mapper.HTTPAddress = globals.HTTPAddress
+	mapper.HTTPToken = globals.HTTPToken
+	mapper.ReadyMaxDisconnected = globals.ReadyMaxDisconnected
+	mapper.Strict = globals.Strict
+	return mapper.Init(configmapPath)
 }
 
-// DevStart start all devices.
-func DevStart() {
-	for id, dev := range devices {
-		klog.V(4).Info("Dev: ", id, dev)
-		start(dev)
-	}
-	wg.Wait()
+// DevValidate check the device profile of the configmap, and return all its problems.
+func DevValidate(configmapPath string) error {
+	return mapper.Validate(configmapPath)
+}
+
+// DevStart start all devices and block until the context is cancelled.
+func DevStart(ctx context.Context) {
+	mapper.Start(ctx)
 }
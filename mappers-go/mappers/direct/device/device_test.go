@@ -0,0 +1,25 @@
+package device
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTwinDeviceID(t *testing.T) {
+	id, ok := getTwinDeviceID("mqtt/input/device/%s/delta", "mqtt/input/device/sensor/delta")
+	assert.True(t, ok)
+	assert.Equal(t, "sensor", id)
+	id, ok = getTwinDeviceID("factory/+/properties", "factory/press-1/properties")
+	assert.True(t, ok)
+	assert.Equal(t, "press-1", id)
+
+	for _, topic := range []string{"mqtt/input/device//delta", "mqtt/input/device/a/b/delta",
+		"mqtt/output/device/sensor/delta", "mqtt/input/device/sensor"} {
+		_, ok = getTwinDeviceID("mqtt/input/device/%s/delta", topic)
+		assert.False(t, ok, topic)
+	}
+	// The input topic of a single device has no device ID.
+	_, ok = getTwinDeviceID("mqtt/input/sensor", "mqtt/input/sensor")
+	assert.False(t, ok)
+}
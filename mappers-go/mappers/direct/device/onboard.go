@@ -0,0 +1,167 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package device
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+	"github.com/kubeedge/mappers-go/mappers/direct/configmap"
+	"github.com/kubeedge/mappers-go/mappers/runtime"
+)
+
+// TopicDeviceOnboard is the topic of the event raised when an unknown device is registered.
+const TopicDeviceOnboard = "$ke/events/device/%s/onboard"
+
+// OnboardEvent is the event raised when an unknown device is registered from a template device.
+type OnboardEvent struct {
+	common.BaseMessage
+	DeviceID string `json:"deviceID"`
+	Template string `json:"template"`
+	Topic    string `json:"topic"`
+}
+
+// initOnboarding find the template devices of the mapper. Their input topic is subscribed
+// with a wildcard instead of one subscription per device.
+func (d *Driver) initOnboarding(devices []*runtime.Device) {
+	templates := make(map[string]*runtime.Device)
+	for _, dev := range devices {
+		id := dev.Instance.ID
+		if dev.Dynamic {
+			continue
+		}
+		var protocolConfig configmap.DirectProtocolConfig
+		if err := json.Unmarshal(dev.Instance.PProtocol.ProtocolConfigs, &protocolConfig); err != nil {
+			continue
+		}
+		if !protocolConfig.MQTTConfigData.Onboarding {
+			continue
+		}
+		inputTopic := protocolConfig.MQTTConfigData.InputTopic
+		if !strings.Contains(inputTopic, "%s") {
+			klog.Errorf("%v can't be onboarding template, input topic %v has no device ID", id, inputTopic)
+			continue
+		}
+		if template, ok := templates[inputTopic]; ok {
+			klog.Errorf("%v can't be onboarding template, %v is the template of %v", id, template.Instance.ID, inputTopic)
+			continue
+		}
+		templates[inputTopic] = dev
+		klog.V(2).Infof("%v is the onboarding template of %v", id, inputTopic)
+	}
+
+	d.templatesMu.Lock()
+	d.templates = templates
+	d.templatesMu.Unlock()
+}
+
+// template return the template device of the input topic.
+func (d *Driver) template(inputTopic string) *runtime.Device {
+	d.templatesMu.Lock()
+	defer d.templatesMu.Unlock()
+
+	return d.templates[inputTopic]
+}
+
+// findTemplate return the template device whose input topic the message came from.
+func (d *Driver) findTemplate(topic string, id string) *runtime.Device {
+	d.templatesMu.Lock()
+	defer d.templatesMu.Unlock()
+
+	for inputTopic, template := range d.templates {
+		if fmt.Sprintf(inputTopic, id) == topic {
+			return template
+		}
+	}
+	return nil
+}
+
+// startOnboarding record that the device is being onboarded, false if it already is.
+func (d *Driver) startOnboarding(id string) bool {
+	d.templatesMu.Lock()
+	defer d.templatesMu.Unlock()
+
+	if d.onboarding[id] {
+		return false
+	}
+	if d.onboarding == nil {
+		d.onboarding = make(map[string]bool)
+	}
+	d.onboarding[id] = true
+	return true
+}
+
+// endOnboarding record that the onboarding of the device is done.
+func (d *Driver) endOnboarding(id string) {
+	d.templatesMu.Lock()
+	defer d.templatesMu.Unlock()
+
+	delete(d.onboarding, id)
+}
+
+// cloneInstance copy the template device instance with a new ID.
+// Twin values of the template are not copied.
+func cloneInstance(template common.DeviceInstance, id string) common.DeviceInstance {
+	instance := template
+	instance.ID = id
+	instance.Name = id
+	instance.PropertyVisitors = append([]common.PropertyVisitor(nil), template.PropertyVisitors...)
+	instance.Twins = append([]common.Twin(nil), template.Twins...)
+	instance.Datas.Properties = append([]common.DataProperty(nil), template.Datas.Properties...)
+
+	findVisitor := func(name string) *common.PropertyVisitor {
+		for i := 0; i < len(instance.PropertyVisitors); i++ {
+			if instance.PropertyVisitors[i].PropertyName == name {
+				return &instance.PropertyVisitors[i]
+			}
+		}
+		return nil
+	}
+	for i := 0; i < len(instance.Twins); i++ {
+		instance.Twins[i].PVisitor = findVisitor(instance.Twins[i].PropertyName)
+		instance.Twins[i].Desired.Value = ""
+		instance.Twins[i].Reported.Value = ""
+	}
+	for i := 0; i < len(instance.Datas.Properties); i++ {
+		instance.Datas.Properties[i].PVisitor = findVisitor(instance.Datas.Properties[i].PropertyName)
+	}
+	return instance
+}
+
+// onboard register and start an unknown device as a copy of the template device,
+// and raise the onboarding event.
+func onboard(mapper *runtime.Mapper, template *runtime.Device, id string, topic string) *runtime.Device {
+	klog.V(1).Infof("Onboard device %v from template %v", id, template.Instance.ID)
+	dev := mapper.AddDevice(cloneInstance(template.Instance, id))
+
+	event := OnboardEvent{BaseMessage: common.NewBaseMessage(), DeviceID: id,
+		Template: template.Instance.ID, Topic: topic}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		klog.Errorf("Create onboard event failed: %v", err)
+		return dev
+	}
+	eventTopic := mapper.Topic(TopicDeviceOnboard, id)
+	if err = mapper.MqttClient.Publish(eventTopic, payload); err != nil {
+		klog.Errorf("Publish topic %v failed, err: %v", eventTopic, err)
+	}
+	return dev
+}
@@ -0,0 +1,48 @@
+package device
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+	"github.com/kubeedge/mappers-go/mappers/runtime"
+)
+
+func TestCloneInstance(t *testing.T) {
+	template := common.DeviceInstance{ID: "mqtt-device",
+		Name: "mqtt-device",
+		PropertyVisitors: []common.PropertyVisitor{{PropertyName: "temperature"},
+			{PropertyName: "humidity"}},
+		Twins: []common.Twin{{PropertyName: "temperature",
+			Desired: common.DesiredData{Value: "20"}}},
+		Datas: common.Data{Properties: []common.DataProperty{{PropertyName: "humidity"}}}}
+	template.Twins[0].PVisitor = &template.PropertyVisitors[0]
+	template.Datas.Properties[0].PVisitor = &template.PropertyVisitors[1]
+
+	instance := cloneInstance(template, "sensor-01")
+	assert.Equal(t, "sensor-01", instance.ID)
+	assert.Equal(t, "sensor-01", instance.Name)
+	assert.Equal(t, "", instance.Twins[0].Desired.Value)
+	assert.True(t, instance.Twins[0].PVisitor == &instance.PropertyVisitors[0])
+	assert.True(t, instance.Datas.Properties[0].PVisitor == &instance.PropertyVisitors[1])
+	assert.Equal(t, "20", template.Twins[0].Desired.Value)
+	assert.True(t, template.Twins[0].PVisitor == &template.PropertyVisitors[0])
+}
+
+func TestFindTemplate(t *testing.T) {
+	template := &runtime.Device{}
+	d := &Driver{templates: map[string]*runtime.Device{"mqtt/input/device/%s/delta": template}}
+	assert.Equal(t, template, d.findTemplate("mqtt/input/device/sensor-01/delta", "sensor-01"))
+	assert.Nil(t, d.findTemplate("mqtt/status/device/sensor-01", "sensor-01"))
+}
+
+func TestOnboarding(t *testing.T) {
+	d := &Driver{}
+	assert.True(t, d.startOnboarding("sensor-01"))
+	// The messages of the device are dropped until it started.
+	assert.False(t, d.startOnboarding("sensor-01"))
+	assert.True(t, d.startOnboarding("sensor-02"))
+	d.endOnboarding("sensor-01")
+	assert.True(t, d.startOnboarding("sensor-01"))
+}
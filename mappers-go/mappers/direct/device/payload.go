@@ -0,0 +1,213 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package device
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kubeedge/mappers-go/mappers/direct/configmap"
+	"github.com/kubeedge/mappers-go/mappers/direct/driver"
+	"github.com/kubeedge/mappers-go/mappers/runtime"
+)
+
+// payloadVisitor extracts the value of one property from the device payload.
+type payloadVisitor struct {
+	Name     string
+	DataType string
+	ReportTo string
+	Config   configmap.VisitorConfigData
+	re       *regexp.Regexp
+}
+
+// newPayloadVisitor parse the visitor config of a property. ReportTo of the property
+// is changed if the visitor config tells where the property goes.
+func newPayloadVisitor(prop *runtime.Property) (*payloadVisitor, error) {
+	var visitorConfig configmap.DirectVisitorConfig
+	if err := json.Unmarshal(prop.Visitor.VisitorConfig, &visitorConfig); err != nil {
+		return nil, fmt.Errorf("unmarshal visitor config of %s failed: %v", prop.Name, err)
+	}
+
+	pv := &payloadVisitor{Name: prop.Name,
+		DataType: prop.DataType,
+		ReportTo: prop.ReportTo,
+		Config:   visitorConfig.VisitorConfigData}
+	if pv.Config.ReportTo != "" {
+		pv.ReportTo = pv.Config.ReportTo
+	}
+	if pv.ReportTo != "" && pv.ReportTo != runtime.ReportToTwin && pv.ReportTo != runtime.ReportToData {
+		return nil, fmt.Errorf("property %s: unknown report destination %q", pv.Name, pv.ReportTo)
+	}
+	if pv.Config.Regex != "" {
+		re, err := regexp.Compile(pv.Config.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("property %s: invalid regex: %v", pv.Name, err)
+		}
+		pv.re = re
+	}
+	if pv.Config.PayloadTemplate != "" {
+		if _, err := driver.ParseTemplate(pv.Config.PayloadTemplate, pv.Config.PayloadFormat); err != nil {
+			return nil, fmt.Errorf("payload template of %s: %v", pv.Name, err)
+		}
+	}
+	prop.ReportTo = pv.ReportTo
+	return pv, nil
+}
+
+// payload is a message from the device. The JSON document is decoded once on demand.
+type payload struct {
+	raw     []byte
+	doc     interface{}
+	err     error
+	decoded bool
+}
+
+// document return the decoded JSON document of the payload.
+func (p *payload) document() (interface{}, error) {
+	if !p.decoded {
+		decoder := json.NewDecoder(bytes.NewReader(p.raw))
+		decoder.UseNumber()
+		p.err = decoder.Decode(&p.doc)
+		p.decoded = true
+	}
+	return p.doc, p.err
+}
+
+// extract get the property value from the payload. found is false if the payload
+// doesn't carry the property.
+func (pv *payloadVisitor) extract(p *payload) (value string, found bool, err error) {
+	switch {
+	case pv.Config.JSONPath != "":
+		return pv.extractJSON(p)
+	case pv.re != nil:
+		match := pv.re.FindSubmatch(p.raw)
+		if match == nil {
+			return "", false, nil
+		}
+		if len(match) > 1 {
+			return string(match[1]), true, nil
+		}
+		return string(match[0]), true, nil
+	case pv.Config.CSVIndex != nil:
+		reader := csv.NewReader(bytes.NewReader(p.raw))
+		if pv.Config.CSVSeparator != "" {
+			reader.Comma = []rune(pv.Config.CSVSeparator)[0]
+		}
+		reader.FieldsPerRecord = -1
+		record, err := reader.Read()
+		if err != nil {
+			return "", false, err
+		}
+		if *pv.Config.CSVIndex < 0 || *pv.Config.CSVIndex >= len(record) {
+			return "", false, nil
+		}
+		return strings.TrimSpace(record[*pv.Config.CSVIndex]), true, nil
+	default:
+		doc, err := p.document()
+		if err != nil {
+			return "", false, err
+		}
+		object, ok := doc.(map[string]interface{})
+		if !ok || object[pv.Config.TopicField] == nil {
+			return "", false, nil
+		}
+		value, err := formatJSONValue(object[pv.Config.TopicField])
+		return value, err == nil, err
+	}
+}
+
+// extractJSON get the value at the JSON path in the payload.
+func (pv *payloadVisitor) extractJSON(p *payload) (string, bool, error) {
+	doc, err := p.document()
+	if err != nil {
+		return "", false, err
+	}
+	v, found, err := lookupJSONPath(doc, pv.Config.JSONPath)
+	if err != nil || !found || v == nil {
+		return "", false, err
+	}
+	value, err := formatJSONValue(v)
+	return value, err == nil, err
+}
+
+// lookupJSONPath walks through the JSON document by a path like "$.env.values[0]".
+// Object keys are separated by dots, array elements are selected by [index].
+func lookupJSONPath(doc interface{}, path string) (interface{}, bool, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return doc, true, nil
+	}
+
+	current := doc
+	for _, segment := range strings.Split(path, ".") {
+		key := segment
+		var indexes []int
+		if i := strings.Index(segment, "["); i >= 0 {
+			key = segment[:i]
+			for rest := segment[i:]; rest != ""; {
+				end := strings.Index(rest, "]")
+				if rest[0] != '[' || end < 0 {
+					return nil, false, fmt.Errorf("invalid JSON path %q", path)
+				}
+				index, err := strconv.Atoi(rest[1:end])
+				if err != nil {
+					return nil, false, fmt.Errorf("invalid index in JSON path %q", path)
+				}
+				indexes = append(indexes, index)
+				rest = rest[end+1:]
+			}
+		}
+
+		if key != "" {
+			object, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, false, nil
+			}
+			if current, ok = object[key]; !ok {
+				return nil, false, nil
+			}
+		}
+		for _, index := range indexes {
+			array, ok := current.([]interface{})
+			if !ok || index < 0 || index >= len(array) {
+				return nil, false, nil
+			}
+			current = array[index]
+		}
+	}
+	return current, true, nil
+}
+
+// formatJSONValue convert a JSON value to the string reported to edgecore.
+func formatJSONValue(v interface{}) (string, error) {
+	switch value := v.(type) {
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	default:
+		b, err := json.Marshal(value)
+		return string(b), err
+	}
+}
@@ -0,0 +1,69 @@
+package device
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+	"github.com/kubeedge/mappers-go/mappers/runtime"
+)
+
+func newTestVisitor(t *testing.T, dataType string, configData string) *payloadVisitor {
+	visitor := common.PropertyVisitor{PropertyName: "temperature",
+		VisitorConfig: json.RawMessage(`{"protocolName":"mqtt","configData":` + configData + `}`)}
+	prop := &runtime.Property{Name: "temperature", DataType: dataType, Visitor: &visitor, ReportTo: runtime.ReportToTwin}
+	pv, err := newPayloadVisitor(prop)
+	assert.Nil(t, err)
+	return pv
+}
+
+func TestExtract(t *testing.T) {
+	cases := []struct {
+		dataType   string
+		configData string
+		payload    string
+		value      string
+		found      bool
+	}{
+		{"string", `{"topicField":"temperature"}`, `{"temperature":"26"}`, "26", true},
+		{"double", `{"jsonPath":"env.t"}`, `{"env":{"t":21.3,"h":40}}`, "21.3", true},
+		{"int", `{"jsonPath":"$.env.h"}`, `{"env":{"t":21.3,"h":40}}`, "40", true},
+		{"int", `{"jsonPath":"values[1]"}`, `{"values":[1,2,3]}`, "2", true},
+		{"int", `{"jsonPath":"env.p"}`, `{"env":{"t":21.3}}`, "", false},
+		{"double", `{"regex":"T=([0-9.]+)"}`, `T=21.5;H=40`, "21.5", true},
+		{"int", `{"csvIndex":1}`, `21.3, 40`, "40", true},
+		{"int", `{"csvIndex":1,"csvSeparator":";"}`, `21.3;41`, "41", true},
+	}
+
+	for _, c := range cases {
+		pv := newTestVisitor(t, c.dataType, c.configData)
+		value, found, err := pv.extract(&payload{raw: []byte(c.payload)})
+		assert.Nil(t, err)
+		assert.Equal(t, c.found, found, c.configData)
+		assert.Equal(t, c.value, value, c.configData)
+	}
+}
+
+func TestNewPayloadVisitorNeg(t *testing.T) {
+	visitor := common.PropertyVisitor{PropertyName: "temperature",
+		VisitorConfig: json.RawMessage(`{"configData":{"regex":"("}}`)}
+	prop := &runtime.Property{Name: "temperature", Visitor: &visitor, ReportTo: runtime.ReportToData}
+	_, err := newPayloadVisitor(prop)
+	assert.NotNil(t, err)
+
+	visitor.VisitorConfig = json.RawMessage(`{"configData":{"reportTo":"cloud"}}`)
+	_, err = newPayloadVisitor(prop)
+	assert.NotNil(t, err)
+
+	visitor.VisitorConfig = json.RawMessage(`{"configData":{"payloadTemplate":"{{.Value"}}`)
+	_, err = newPayloadVisitor(prop)
+	assert.NotNil(t, err)
+
+	visitor.VisitorConfig = json.RawMessage(`{"configData":{"reportTo":"data"}}`)
+	prop.ReportTo = ""
+	_, err = newPayloadVisitor(prop)
+	assert.Nil(t, err)
+	assert.Equal(t, runtime.ReportToData, prop.ReportTo)
+}
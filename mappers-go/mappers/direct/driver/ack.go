@@ -0,0 +1,134 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"k8s.io/klog/v2"
+)
+
+// CorrelationField is the field of the correlation ID in commands and acknowledgements.
+const CorrelationField = "correlationId"
+
+// Ack is the acknowledgement the device publishes to the ack topic.
+// A non-empty Error means the device rejected the command.
+type Ack struct {
+	CorrelationID string `json:"correlationId"`
+	Error         string `json:"error,omitempty"`
+}
+
+// pendingSet is a command waiting for confirmation.
+type pendingSet struct {
+	id    string
+	name  string
+	value string
+	done  chan error
+}
+
+// newCorrelationID generate a random correlation ID.
+func newCorrelationID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		klog.Errorf("Generate correlation ID failed: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func (c *DirectClient) addPending(name string, value string) *pendingSet {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ps := &pendingSet{id: newCorrelationID(), name: name, value: value, done: make(chan error, 1)}
+	if c.pending == nil {
+		c.pending = make(map[string]*pendingSet)
+	}
+	c.pending[ps.id] = ps
+	return ps
+}
+
+func (c *DirectClient) removePending(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.pending, id)
+}
+
+// confirm finish a pending command.
+func (ps *pendingSet) confirm(err error) {
+	select {
+	case ps.done <- err:
+	default:
+	}
+}
+
+// SubscribeAck subscribe the ack topic of the device on the device broker.
+func (c *DirectClient) SubscribeAck() error {
+	directConfig, ok := c.Config.(DirectConfig)
+	if !ok || directConfig.AckTopic == "" {
+		return nil
+	}
+	klog.V(1).Info("Subscribe ack topic: ", directConfig.AckTopic)
+	return c.Client.Subscribe(directConfig.AckTopic, func(client mqtt.Client, message mqtt.Message) {
+		c.HandleAck(message.Payload())
+	})
+}
+
+// HandleAck confirm the command acknowledged by the payload.
+func (c *DirectClient) HandleAck(payload []byte) {
+	var ack Ack
+	if err := json.Unmarshal(payload, &ack); err != nil {
+		klog.Errorf("Unmarshal ack failed: %v", err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ps, ok := c.pending[ack.CorrelationID]
+	if !ok {
+		klog.V(2).Info("Ack of unknown command: ", ack.CorrelationID)
+		return
+	}
+	if ack.Error != "" {
+		ps.confirm(errors.New(ack.Error))
+		return
+	}
+	ps.confirm(nil)
+}
+
+// Observe record a value the device reported, and confirm the pending commands of the
+// property once the device reports the written value.
+func (c *DirectClient) Observe(name string, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.values == nil {
+		c.values = make(map[string]string)
+	}
+	c.values[name] = value
+
+	for _, ps := range c.pending {
+		if ps.name == name && ps.value == value {
+			ps.confirm(nil)
+		}
+	}
+}
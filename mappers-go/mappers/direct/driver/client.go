@@ -17,11 +17,14 @@ limitations under the License.
 package driver
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"sync"
+	"time"
 
-	"encoding/json"
-
+	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"k8s.io/klog/v2"
 
 	"github.com/kubeedge/mappers-go/mappers/common"
@@ -34,46 +37,79 @@ type DirectConfig struct {
 	Password      string `json:"password,omitempty"`
 	Cert          string `json:"certification,omitempty"`
 	Topic         string `json:"topic,omitempty"`
+	// InputTopic is the topic the device publishes its properties to, "%s" is the device ID.
+	InputTopic string `json:"inputTopic,omitempty"`
+	// StatusTopic is the topic the device publishes its online/offline payload to.
+	StatusTopic      string        `json:"statusTopic,omitempty"`
+	OnlinePayload    string        `json:"onlinePayload,omitempty"`
+	OfflinePayload   string        `json:"offlinePayload,omitempty"`
+	HeartbeatTimeout time.Duration `json:"heartbeatTimeout,omitempty"`
+	// AckTopic is the topic the device acknowledges commands on.
+	AckTopic   string        `json:"ackTopic,omitempty"`
+	AckTimeout time.Duration `json:"ackTimeout,omitempty"`
+	AckRetries int           `json:"ackRetries,omitempty"`
 }
 
 // DirectClient is the structure for direct client.
 type DirectClient struct {
 	//Client  modbus.Client
-	Client common.MqttClient
+	Client *common.MqttClient
 	//Handler interface{}
 	Config interface{}
 	Topic  string `json:"topic,omitempty"`
 
 	mu sync.Mutex
+
+	// lastSeen is the last time the device published anything.
+	lastSeen time.Time
+	// offline is set when the device published its offline or last-will payload.
+	offline bool
+
+	// setMu serializes the writes to the device.
+	setMu   sync.Mutex
+	pending map[string]*pendingSet
+
+	// values are the last values the device reported by property name.
+	values map[string]string
 }
 
-var clients map[string]*DirectClient
+// connections are the broker connections shared by the devices behind the same broker.
+var connections map[string]*common.MqttClient
+
+// refs counts the devices sharing the connection of an address.
+var refs map[string]int
+
+// connectionsMu protects connections and refs.
+var connectionsMu sync.Mutex
 
 func newMQTTClient(config DirectConfig) (*DirectClient, error) {
 	addr := config.ServerAddress
-	var mqttClent common.MqttClient
 	var err error
 
-	if client, ok := clients[addr]; ok {
-		return client, nil
-	}
+	connectionsMu.Lock()
+	defer connectionsMu.Unlock()
 
-	if clients == nil {
-		clients = make(map[string]*DirectClient)
+	if connections == nil {
+		connections = make(map[string]*common.MqttClient)
+		refs = make(map[string]int)
 	}
 
-	//mqttClent = common.MqttClient{IP: "tcp://127.0.0.1:1883",
-	mqttClent = common.MqttClient{IP: config.ServerAddress,
-		User:       config.Username,
-		Passwd:     config.Password,
-		Cert:       config.Cert,
-		PrivateKey: ""}
-	if err = mqttClent.Connect(); err != nil {
-		klog.Fatal(err)
+	mqttClient, ok := connections[addr]
+	if !ok {
+		//mqttClent = common.MqttClient{IP: "tcp://127.0.0.1:1883",
+		mqttClient = &common.MqttClient{IP: config.ServerAddress,
+			User:       config.Username,
+			Passwd:     config.Password,
+			Cert:       config.Cert,
+			PrivateKey: ""}
+		if err = mqttClient.Connect(); err != nil {
+			return nil, fmt.Errorf("connect %s: %v", addr, err)
+		}
+		connections[addr] = mqttClient
 	}
+	refs[addr]++
 
-	client := DirectClient{Client: mqttClent, Config: config, Topic: config.Topic}
-	clients[addr] = &client
+	client := DirectClient{Client: mqttClient, Config: config, Topic: config.Topic, lastSeen: time.Now()}
 	return &client, err
 }
 
@@ -87,19 +123,105 @@ func NewClient(config interface{}) (*DirectClient, error) {
 	}
 }
 
+// Close release the client, the broker connection is closed when no device uses it.
+func (c *DirectClient) Close() error {
+	directConfig, _ := c.Config.(DirectConfig)
+	addr := directConfig.ServerAddress
+
+	connectionsMu.Lock()
+	defer connectionsMu.Unlock()
+
+	if connections[addr] != c.Client {
+		return nil
+	}
+	if refs[addr]--; refs[addr] > 0 {
+		return nil
+	}
+	delete(connections, addr)
+	delete(refs, addr)
+	c.Client.Client.Disconnect(250)
+	return nil
+}
+
+// Unsubscribe unsubscribe the status and ack topics of the device.
+func (c *DirectClient) Unsubscribe() error {
+	directConfig, _ := c.Config.(DirectConfig)
+	var topics []string
+	if directConfig.StatusTopic != "" {
+		topics = append(topics, directConfig.StatusTopic)
+	}
+	if directConfig.AckTopic != "" {
+		topics = append(topics, directConfig.AckTopic)
+	}
+	if len(topics) == 0 {
+		return nil
+	}
+	return c.Client.Unsubscribe(topics...)
+}
+
+// Value return the last value the device reported for the property.
+func (c *DirectClient) Value(name string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	value, ok := c.values[name]
+	return value, ok
+}
+
+// SubscribeStatus subscribe the status topic of the device on the device broker.
+func (c *DirectClient) SubscribeStatus(onMessage mqtt.MessageHandler) error {
+	directConfig, ok := c.Config.(DirectConfig)
+	if !ok || directConfig.StatusTopic == "" {
+		return nil
+	}
+	klog.V(1).Info("Subscribe status topic: ", directConfig.StatusTopic)
+	return c.Client.Subscribe(directConfig.StatusTopic, onMessage)
+}
+
+// Heartbeat records that the device is alive.
+func (c *DirectClient) Heartbeat() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lastSeen = time.Now()
+	c.offline = false
+}
+
+// UpdateLiveness updates the device liveness from a payload published on the status topic.
+// The offline payload marks the device as disconnected, any other payload counts as a heartbeat.
+func (c *DirectClient) UpdateLiveness(payload []byte) {
+	directConfig, _ := c.Config.(DirectConfig)
+	payload = bytes.TrimSpace(payload)
+	if directConfig.OfflinePayload != "" && string(payload) == directConfig.OfflinePayload {
+		c.mu.Lock()
+		c.offline = true
+		c.mu.Unlock()
+		klog.V(1).Infof("Device on topic %v is offline", directConfig.StatusTopic)
+		return
+	}
+	if directConfig.OnlinePayload != "" && string(payload) != directConfig.OnlinePayload {
+		klog.V(2).Infof("Unknown status payload %s on topic %v", payload, directConfig.StatusTopic)
+	}
+	c.Heartbeat()
+}
+
 // GetStatus get device status.
-// Now we could only get the connection status.
+// The device is disconnected if the broker connection is lost, the device published
+// its offline payload, or nothing was heard from it within the heartbeat timeout.
 func (c *DirectClient) GetStatus() string {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	//err := c.Client.Connect()
 	isConnected := c.Client.Client.IsConnected()
-
-	if isConnected == true {
-		return common.DEVSTOK
+	if !isConnected || c.offline {
+		return common.DEVSTDISCONN
 	}
-	return common.DEVSTDISCONN
+	if directConfig, ok := c.Config.(DirectConfig); ok && directConfig.HeartbeatTimeout > 0 &&
+		time.Since(c.lastSeen) > directConfig.HeartbeatTimeout {
+		return common.DEVSTDISCONN
+	}
+	return common.DEVSTOK
 }
 
 // Get get register.
@@ -110,24 +232,85 @@ func (c *DirectClient) GetStatus() string {
 	return results, err
 }*/
 
-// Set set register.
-func (c *DirectClient) Set(topicField string, value string) (results []byte, err error) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// Set set the property, the payload rendered from the command is published to the output topic.
+// If acknowledgement is configured, the command carries a correlation ID and Set waits until
+// the device acknowledges it or reports the value, and sends it again on timeout until
+// the context is done.
+func (c *DirectClient) Set(ctx context.Context, cmd Command) (results []byte, err error) {
+	c.setMu.Lock()
+	defer c.setMu.Unlock()
 
-	klog.V(1).Infof("Set %v to %v", topicField, value)
+	klog.V(1).Infof("Set %v to %v", cmd.Name, cmd.Value)
 
-	topicValueMap := map[string]string{topicField: value}
-	results, _ = json.Marshal(topicValueMap)
+	directConfig, _ := c.Config.(DirectConfig)
+	topic := directConfig.Topic
+	if cmd.Topic != "" {
+		topic = cmd.Topic
+	}
+	data := CommandData{DeviceID: cmd.DeviceID,
+		PropertyName: cmd.Name,
+		Value:        cmd.TypedValue,
+		RawValue:     cmd.Value,
+		Timestamp:    time.Now().UnixNano() / 1e6}
+	if data.Value == nil {
+		data.Value = cmd.Value
+	}
 
-	if directConfig, ok := c.Config.(DirectConfig); ok {
-		if err = c.Client.Publish(directConfig.Topic, results); err != nil {
-			klog.Errorf("Publish topic %v failed, err: %v", directConfig.Topic, err)
-		} else {
-			klog.V(1).Infof("Publish topic %v successfully, value: %v", directConfig.Topic, topicValueMap)
+	if directConfig.AckTimeout <= 0 {
+		if results, err = cmd.render(data); err != nil {
+			return nil, err
 		}
+		err = c.publish(topic, results)
+		klog.V(1).Info("Set result:", err, cmd.Value)
+		return results, err
 	}
 
-	klog.V(1).Info("Set result:", err, value)
+	ps := c.addPending(cmd.Name, cmd.Value)
+	defer c.removePending(ps.id)
+	data.CorrelationID = ps.id
+	if results, err = cmd.render(data); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i <= directConfig.AckRetries; i++ {
+		if i > 0 && ctx.Err() != nil {
+			break
+		}
+		if err = c.publish(topic, results); err != nil {
+			// The broker is given the ack timeout to recover, as the device is.
+			select {
+			case <-ctx.Done():
+				return results, ctx.Err()
+			case <-time.After(directConfig.AckTimeout):
+			}
+			continue
+		}
+		select {
+		case err = <-ps.done:
+		case <-ctx.Done():
+			// The device stops, the write is abandoned instead of failed.
+			return results, ctx.Err()
+		case <-time.After(directConfig.AckTimeout):
+			err = fmt.Errorf("no acknowledgement of %s in %v", ps.id, directConfig.AckTimeout)
+			klog.Warningf("Set %v to %v: %v", cmd.Name, cmd.Value, err)
+			continue
+		}
+		break
+	}
+
+	klog.V(1).Info("Set result:", err, cmd.Value)
 	return results, err
 }
+
+// publish publish a command to the device.
+func (c *DirectClient) publish(topic string, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.Client.Publish(topic, payload); err != nil {
+		klog.Errorf("Publish topic %v failed, err: %v", topic, err)
+		return err
+	}
+	klog.V(1).Infof("Publish topic %v successfully, value: %s", topic, payload)
+	return nil
+}
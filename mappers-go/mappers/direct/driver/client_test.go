@@ -20,62 +20,33 @@ limitations under the License.
 package driver
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"time"
 )
 
 func tdriver() {
-	var directrtu DirectRTU
+	var config DirectConfig
 
-	directrtu.SerialName = "/dev/ttyS0"
-	directrtu.BaudRate = 9600
-	directrtu.DataBits = 8
-	directrtu.StopBits = 1
-	directrtu.SlaveID = 1
-	directrtu.Parity = "N"
-	directrtu.Timeout = 2 * time.Second
+	config.ServerAddress = "tcp://127.0.0.1:1883"
+	config.Topic = "mqtt/output/device/mqtt-device/delta"
 
-	client, err := NewClient(directrtu)
+	client, err := NewClient(config)
 	if err != nil {
 		fmt.Println("New client error")
 		os.Exit(1)
 	}
 
-	results, err := client.Set("DiscreteInputRegister", 2, 1)
-	if err != nil {
-		fmt.Println(err)
-	}
-	fmt.Println(results)
-	results, err = client.Set("CoilRegister", 2, 1)
+	results, err := client.Set(context.Background(), Command{Name: "temperature-enable", TopicField: "temperature-enable", Value: "1"})
 	if err != nil {
 		fmt.Println(err)
 	}
 	fmt.Println(results)
+	fmt.Println("status: ", client.GetStatus())
 	os.Exit(0)
 }
 
 func main() {
-	/*
-		var directtcp DirectTCP
-
-		directtcp.DeviceIp = "192.168.56.1"
-		directtcp.TcpPort = "502"
-		directtcp.SlaveId = 0x1
-		client := NewClient(directtcp)
-		if client == nil {
-			fmt.Println("New client error")
-			os.Exit(1)
-		}
-		fmt.Println("status: ", client.GetStatus())
-
-		results, err := client.Client.ReadDiscreteInputs(0, 1)
-		if err != nil {
-			fmt.Println("Read error: ", err)
-			os.Exit(1)
-		}
-		fmt.Println("result: ", results)
-	*/
 	tdriver()
 	os.Exit(0)
 }
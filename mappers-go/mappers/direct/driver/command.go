@@ -0,0 +1,133 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Payload formats of the rendered command.
+const (
+	FormatText   = "text"
+	FormatJSON   = "json"
+	FormatHex    = "hex"
+	FormatBase64 = "base64"
+)
+
+// Command is a property write to the device.
+type Command struct {
+	Name       string
+	TopicField string
+	Value      string
+	// TypedValue is Value converted to the data type of the property.
+	TypedValue interface{}
+	// Topic overrides the output topic of the device if it is set.
+	Topic string
+	// Template renders the payload. The default payload is {TopicField: Value}.
+	Template string
+	Format   string
+	DeviceID string
+}
+
+// CommandData is the data the payload template is executed with.
+type CommandData struct {
+	DeviceID      string
+	PropertyName  string
+	Value         interface{}
+	RawValue      string
+	Timestamp     int64
+	CorrelationID string
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"hex": func(v interface{}) string {
+		return hex.EncodeToString([]byte(fmt.Sprint(v)))
+	},
+}
+
+// templates caches the parsed payload templates by their text.
+var templates = struct {
+	sync.Mutex
+	m map[string]*template.Template
+}{m: make(map[string]*template.Template)}
+
+// ParseTemplate parse a payload template and check the payload format.
+func ParseTemplate(text string, format string) (*template.Template, error) {
+	switch format {
+	case "", FormatText, FormatJSON, FormatHex, FormatBase64:
+	default:
+		return nil, fmt.Errorf("unknown payload format %q", format)
+	}
+
+	templates.Lock()
+	defer templates.Unlock()
+
+	if t, ok := templates.m[text]; ok {
+		return t, nil
+	}
+	t, err := template.New("payload").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	templates.m[text] = t
+	return t, nil
+}
+
+// render build the payload of the command.
+func (cmd *Command) render(data CommandData) ([]byte, error) {
+	if cmd.Template == "" {
+		topicValueMap := map[string]string{cmd.TopicField: cmd.Value}
+		if data.CorrelationID != "" {
+			topicValueMap[CorrelationField] = data.CorrelationID
+		}
+		return json.Marshal(topicValueMap)
+	}
+
+	t, err := ParseTemplate(cmd.Template, cmd.Format)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err = t.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+
+	switch cmd.Format {
+	case FormatJSON:
+		if !json.Valid(buf.Bytes()) {
+			return nil, fmt.Errorf("payload of %s is not valid JSON: %s", cmd.Name, buf.String())
+		}
+		return buf.Bytes(), nil
+	case FormatHex:
+		return hex.DecodeString(strings.Join(strings.Fields(buf.String()), ""))
+	case FormatBase64:
+		return base64.StdEncoding.DecodeString(strings.TrimSpace(buf.String()))
+	default:
+		return buf.Bytes(), nil
+	}
+}
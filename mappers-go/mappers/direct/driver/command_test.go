@@ -0,0 +1,78 @@
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+func TestRender(t *testing.T) {
+	data := CommandData{DeviceID: "mqtt-device",
+		PropertyName:  "temperature",
+		Value:         int64(26),
+		RawValue:      "26",
+		Timestamp:     1550049403598,
+		CorrelationID: "01"}
+
+	cases := []struct {
+		cmd     Command
+		payload []byte
+	}{
+		{Command{TopicField: "temperature", Value: "26"},
+			[]byte(`{"correlationId":"01","temperature":"26"}`)},
+		{Command{Template: `{"dev":{{json .DeviceID}},"set":{"{{.PropertyName}}":{{.Value}}},"ts":{{.Timestamp}}}`, Format: FormatJSON},
+			[]byte(`{"dev":"mqtt-device","set":{"temperature":26},"ts":1550049403598}`)},
+		{Command{Template: `SET {{.PropertyName}} {{.RawValue}}`, Format: FormatText},
+			[]byte(`SET temperature 26`)},
+		{Command{Template: `01 06 {{printf "%04x" .Value}}`, Format: FormatHex},
+			[]byte{0x01, 0x06, 0x00, 0x1a}},
+		{Command{Template: `AQY=`, Format: FormatBase64},
+			[]byte{0x01, 0x06}},
+	}
+
+	for _, c := range cases {
+		payload, err := c.cmd.render(data)
+		assert.Nil(t, err)
+		assert.Equal(t, c.payload, payload)
+	}
+}
+
+func TestRenderNeg(t *testing.T) {
+	_, err := ParseTemplate(`{{.Value`, FormatText)
+	assert.NotNil(t, err)
+
+	_, err = ParseTemplate(`{{.Value}}`, "xml")
+	assert.NotNil(t, err)
+
+	cmd := Command{Template: `{"t":{{.RawValue}}`, Format: FormatJSON}
+	_, err = cmd.render(CommandData{RawValue: "26"})
+	assert.NotNil(t, err)
+}
+
+func TestNewClientConnectError(t *testing.T) {
+	// Nothing listens on the port, the error is returned to fail only this device.
+	_, err := NewClient(DirectConfig{ServerAddress: "tcp://127.0.0.1:1"})
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, refs["tcp://127.0.0.1:1"])
+}
+
+func TestSetPublishRetry(t *testing.T) {
+	// The client isn't connected, every publish fails.
+	client := &DirectClient{Client: &common.MqttClient{Client: mqtt.NewClient(mqtt.NewClientOptions())},
+		Config: DirectConfig{Topic: "mqtt/output", AckTimeout: 20 * time.Millisecond, AckRetries: 2}}
+	start := time.Now()
+	_, err := client.Set(context.Background(), Command{Name: "switch", TopicField: "switch", Value: "on"})
+	assert.NotNil(t, err)
+	// The retries wait for the ack timeout after each failed publish.
+	assert.True(t, time.Since(start) >= 60*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.Set(ctx, Command{Name: "switch", TopicField: "switch", Value: "on"})
+	assert.Equal(t, context.Canceled, err)
+}
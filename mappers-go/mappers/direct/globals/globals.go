@@ -18,16 +18,19 @@ package globals
 
 import (
 	"github.com/kubeedge/mappers-go/mappers/common"
-	"github.com/kubeedge/mappers-go/mappers/direct/driver"
 )
 
-// DirectDev is the direct device configuration and client information.
-type DirectDev struct {
-	Instance     common.DeviceInstance
-	DirectClient *driver.DirectClient
-	Topic        string
-}
-
 var MqttClient common.MqttClient
 
 var LocalTest bool
+
+// HTTPAddress and HTTPToken configure the embedded HTTP server of the mapper.
+var HTTPAddress string
+
+var HTTPToken string
+
+// ReadyMaxDisconnected is the percentage of disconnected devices above which the mapper is not ready.
+var ReadyMaxDisconnected int
+
+// Strict refuses a configmap with any problem instead of starting the valid devices.
+var Strict bool
@@ -0,0 +1,154 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+// DefaultAttributeCycle is how often a dynamic attribute is read if its collect cycle
+// is not set.
+const DefaultAttributeCycle = time.Minute
+
+// attribute is an attribute of a device with its current value.
+type attribute struct {
+	Name string
+	Type string
+	// prop reads a dynamic attribute by the driver, it is nil for a static attribute.
+	prop *Property
+	// value is guarded by the mutex of the device.
+	value string
+}
+
+// initAttributes initialize the attributes of the device instance. The visitor config of
+// the dynamic attributes is parsed by the driver, as for a read only property.
+func (d *Device) initAttributes() error {
+	attributes := make([]*attribute, 0, len(d.Instance.Attributes))
+	for i := 0; i < len(d.Instance.Attributes); i++ {
+		attr, err := d.newAttribute(&d.Instance.Attributes[i])
+		if err != nil {
+			return err
+		}
+		attributes = append(attributes, attr)
+	}
+
+	d.mu.Lock()
+	d.attributes = attributes
+	d.attributesChanged = len(attributes) > 0
+	d.mu.Unlock()
+	return nil
+}
+
+// newAttribute build the attribute of the device instance.
+func (d *Device) newAttribute(instance *common.Attribute) (*attribute, error) {
+	if instance.Name == "" {
+		return nil, fmt.Errorf("attribute has no name")
+	}
+	attr := &attribute{Name: instance.Name, Type: instance.Type, value: instance.Value}
+	if attr.Type == "" {
+		attr.Type = "string"
+	}
+	if len(instance.VisitorConfig) > 0 {
+		visitor := &common.PropertyVisitor{PropertyName: instance.Name,
+			CollectCycle:  instance.CollectCycle,
+			VisitorConfig: instance.VisitorConfig,
+			PProperty: common.Property{Name: instance.Name, DataType: "string",
+				AccessMode: common.AccessModeReadOnly}}
+		attr.prop = &Property{Name: instance.Name, DataType: "string", Visitor: visitor}
+		if err := d.mapper.Driver.ParseVisitor(d, attr.prop); err != nil {
+			return nil, fmt.Errorf("parse visitor of attribute %s failed: %v", instance.Name, err)
+		}
+	}
+	return attr, nil
+}
+
+// readAttributes read the dynamic attributes from the device. The attributes are
+// published with the next state if a value changed.
+func (d *Device) readAttributes() {
+	d.mu.Lock()
+	attributes := append([]*attribute(nil), d.attributes...)
+	d.mu.Unlock()
+
+	for _, attr := range attributes {
+		if attr.prop != nil {
+			d.readAttribute(attr)
+		}
+	}
+}
+
+// readAttribute read the dynamic attribute from the device.
+func (d *Device) readAttribute(attr *attribute) {
+	value, err := d.mapper.Driver.ReadProperty(d, attr.prop)
+	if err != nil {
+		klog.V(2).Infof("Get attribute %s of %s failed: %v", attr.Name, d.Instance.ID, err)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if attr.value != value {
+		klog.V(1).Infof("Attribute %s of %s changed to %s", attr.Name, d.Instance.ID, value)
+		attr.value = value
+		d.attributesChanged = true
+	}
+}
+
+// initAttributeTimers start a timer for each dynamic attribute to read it again.
+func (d *Device) initAttributeTimers() {
+	for _, attr := range d.attributes {
+		if attr.prop == nil {
+			continue
+		}
+		cycle := time.Duration(attr.prop.Visitor.CollectCycle) * time.Millisecond
+		if cycle == 0 {
+			cycle = DefaultAttributeCycle
+		}
+		attr := attr
+		d.startTimer("attribute "+attr.Name, func() { d.readAttribute(attr) }, cycle)
+	}
+}
+
+// changedAttributes return all attributes of the device if one changed since they were
+// last taken, and nil otherwise.
+func (d *Device) changedAttributes() []common.PropertyValue {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.attributesChanged {
+		return nil
+	}
+	d.attributesChanged = false
+	values := make([]common.PropertyValue, 0, len(d.attributes))
+	for _, attr := range d.attributes {
+		values = append(values, common.PropertyValue{Name: attr.Name, Type: attr.Type, Value: attr.value})
+	}
+	return values
+}
+
+// setAttributesChanged publish the attributes with the next state again, after the
+// publish failed.
+func (d *Device) setAttributesChanged() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.attributesChanged = true
+}
@@ -0,0 +1,36 @@
+package runtime
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+func TestAttributes(t *testing.T) {
+	driver := &fakeDriver{values: map[string]string{"firmware": "1.0.2"}}
+	m := NewMapper(driver, nil)
+	instance := common.DeviceInstance{ID: "sensor",
+		Attributes: []common.Attribute{{Name: "serialNumber", Value: "SN-1234"},
+			{Name: "firmware", VisitorConfig: json.RawMessage(`{"configData":{"pathField":"fw"}}`)}}}
+	dev := &Device{Instance: instance, mapper: m}
+	assert.Nil(t, dev.initProperties())
+	assert.Equal(t, `{"configData":{"pathField":"fw"}}`, dev.attributes[1].prop.Config)
+
+	dev.readAttributes()
+	assert.Equal(t, []common.PropertyValue{{Name: "serialNumber", Type: "string", Value: "SN-1234"},
+		{Name: "firmware", Type: "string", Value: "1.0.2"}}, dev.changedAttributes())
+	assert.Nil(t, dev.changedAttributes())
+
+	// Unchanged values are not published again.
+	dev.readAttributes()
+	assert.Nil(t, dev.changedAttributes())
+
+	driver.values["firmware"] = "1.1.0"
+	dev.readAttributes()
+	values := dev.changedAttributes()
+	assert.Equal(t, 2, len(values))
+	assert.Equal(t, "1.1.0", values[1].Value)
+}
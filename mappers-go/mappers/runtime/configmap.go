@@ -0,0 +1,135 @@
+/*
+Copyright 2020 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"k8s.io/klog/v2"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+// Parse parse the configmap. Only a configmap which can't be read or isn't JSON is an
+// error. A device instance which refers to a missing protocol, model, property or property
+// visitor is parsed with the reason, it fails to start while the other devices run.
+func Parse(path string,
+	devices map[string]*Device,
+	dms map[string]common.DeviceModel,
+	protocols map[string]common.Protocol) error {
+	var deviceProfile common.DeviceProfile
+
+	jsonFile, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err = json.Unmarshal(jsonFile, &deviceProfile); err != nil {
+		return err
+	}
+
+	for i := 0; i < len(deviceProfile.DeviceInstances); i++ {
+		instance := deviceProfile.DeviceInstances[i]
+		dev := &Device{}
+		if dev.err = linkInstance(&deviceProfile, &instance); dev.err != nil {
+			klog.Errorf("Device %s at $.deviceInstances[%d] is invalid: %v", instance.ID, i, dev.err)
+		}
+		dev.Instance = instance
+		devices[instance.ID] = dev
+		klog.V(4).Info("Instance: ", instance.ID, instance)
+	}
+
+	for i := 0; i < len(deviceProfile.DeviceModels); i++ {
+		dms[deviceProfile.DeviceModels[i].Name] = deviceProfile.DeviceModels[i]
+	}
+
+	for i := 0; i < len(deviceProfile.Protocols); i++ {
+		protocols[deviceProfile.Protocols[i].Name] = deviceProfile.Protocols[i]
+	}
+	return nil
+}
+
+// linkInstance set the protocol, the device model properties and the property visitors
+// the device instance refers to.
+func linkInstance(deviceProfile *common.DeviceProfile, instance *common.DeviceInstance) error {
+	j := 0
+	for j = 0; j < len(deviceProfile.Protocols); j++ {
+		if instance.ProtocolName == deviceProfile.Protocols[j].Name {
+			instance.PProtocol = deviceProfile.Protocols[j]
+			break
+		}
+	}
+	if j == len(deviceProfile.Protocols) {
+		return fmt.Errorf("protocol %q not found", instance.ProtocolName)
+	}
+
+	for k := 0; k < len(instance.PropertyVisitors); k++ {
+		modelName := instance.PropertyVisitors[k].ModelName
+		propertyName := instance.PropertyVisitors[k].PropertyName
+		l := 0
+		for l = 0; l < len(deviceProfile.DeviceModels); l++ {
+			if modelName == deviceProfile.DeviceModels[l].Name {
+				m := 0
+				for m = 0; m < len(deviceProfile.DeviceModels[l].Properties); m++ {
+					if propertyName == deviceProfile.DeviceModels[l].Properties[m].Name {
+						instance.PropertyVisitors[k].PProperty = deviceProfile.DeviceModels[l].Properties[m]
+						break
+					}
+				}
+
+				if m == len(deviceProfile.DeviceModels[l].Properties) {
+					return fmt.Errorf("property %q not found in device model %q", propertyName, modelName)
+				}
+				break
+			}
+		}
+		if l == len(deviceProfile.DeviceModels) {
+			return fmt.Errorf("device model %q not found", modelName)
+		}
+	}
+
+	for k := 0; k < len(instance.Twins); k++ {
+		name := instance.Twins[k].PropertyName
+		l := 0
+		for l = 0; l < len(instance.PropertyVisitors); l++ {
+			if name == instance.PropertyVisitors[l].PropertyName {
+				instance.Twins[k].PVisitor = &instance.PropertyVisitors[l]
+				break
+			}
+		}
+		if l == len(instance.PropertyVisitors) {
+			return fmt.Errorf("twin %q has no property visitor", name)
+		}
+	}
+
+	for k := 0; k < len(instance.Datas.Properties); k++ {
+		name := instance.Datas.Properties[k].PropertyName
+		l := 0
+		for l = 0; l < len(instance.PropertyVisitors); l++ {
+			if name == instance.PropertyVisitors[l].PropertyName {
+				instance.Datas.Properties[k].PVisitor = &instance.PropertyVisitors[l]
+				break
+			}
+		}
+		if l == len(instance.PropertyVisitors) {
+			return fmt.Errorf("data property %q has no property visitor", name)
+		}
+	}
+	return nil
+}
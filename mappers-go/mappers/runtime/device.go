@@ -0,0 +1,634 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+// Report destinations of a property value.
+const (
+	ReportToTwin = "twin"
+	ReportToData = "data"
+)
+
+// Device is a device instance and the driver client of it.
+type Device struct {
+	Instance common.DeviceInstance
+	// Client is the driver client of the device, set by Driver.Connect.
+	Client interface{}
+	// Properties are the property visitors of the device by property name. They are set
+	// when the device starts, under mu for the readers outside of the device.
+	Properties map[string]*Property
+
+	// Dynamic is set for the devices added by AddDevice instead of the configmap.
+	// They are kept when the configmap is reloaded.
+	Dynamic bool
+
+	mapper *Mapper
+	// profile is the device instance as parsed from the configmap, to find changed devices.
+	profile []byte
+	// writeMu serializes the writes to the device.
+	writeMu sync.Mutex
+	// ctx is cancelled when the device stops, wg waits for its timers and writes.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu sync.Mutex
+	// started is set when the device has started, until it stops.
+	started bool
+	// timers are the states of the running timers of the device.
+	timers []*timerState
+	// attributes are the attributes of the device, published with the state when
+	// attributesChanged is set.
+	attributes        []*attribute
+	attributesChanged bool
+	// err is why the device is invalid or failed to start.
+	err error
+}
+
+// Property is a property visitor of a device with its parsed visitor config.
+type Property struct {
+	Name     string
+	DataType string
+	Visitor  *common.PropertyVisitor
+	// Config is the visitor config parsed by Driver.ParseVisitor.
+	Config interface{}
+	// ReportTo is where the values pushed by the device are published, ReportToTwin,
+	// ReportToData or empty if they are not published. The twin goes to twin and the
+	// data property goes to data, drivers may change it in ParseVisitor.
+	ReportTo string
+	// Report is the report config in the visitor config.
+	Report common.ReportConfig
+
+	// aggregator buffers the samples between reports if the property has a report cycle.
+	aggregator *aggregator
+	// validator checks the values written to the property.
+	validator *validator
+	// deviceUnit is the unit of the device values, if they are converted to the unit of
+	// the property in the device model.
+	deviceUnit string
+	// readExpression and writeExpression transform the values read from and written to
+	// the device, if they are set.
+	readExpression  *common.Expression
+	writeExpression *common.Expression
+
+	mu sync.Mutex
+	// value is the last value read from the device at updated.
+	value   string
+	updated time.Time
+	// reported is the last value reported at reportedAt.
+	reported   string
+	reportedAt time.Time
+}
+
+// ErrReadOnly is returned when a read only property is written.
+var ErrReadOnly = errors.New("property is read only")
+
+// ErrWriteOnly is returned when a write only property is read.
+var ErrWriteOnly = errors.New("property is write only")
+
+// ErrNotApplied is wrapped by the driver errors of the writes the device didn't apply, such
+// as a write it didn't acknowledge. They are reported in the twin error of the property.
+var ErrNotApplied = errors.New("not applied by the device")
+
+// AccessMode return the access mode of the property, ReadWrite if it is not set.
+func (p *Property) AccessMode() string {
+	if p.Visitor.PProperty.AccessMode == "" {
+		return common.AccessModeReadWrite
+	}
+	return p.Visitor.PProperty.AccessMode
+}
+
+// DefaultValue return the default value of the property in the device model, if it has one.
+func (p *Property) DefaultValue() (string, bool) {
+	switch value := p.Visitor.PProperty.DefaultValue.(type) {
+	case nil:
+		return "", false
+	case string:
+		return value, value != ""
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), true
+	default:
+		return fmt.Sprint(value), true
+	}
+}
+
+// Value return the last value read from the device and when it was read.
+func (p *Property) Value() (string, time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.value, p.updated
+}
+
+// setValue record a value read from the device.
+func (p *Property) setValue(value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.value = value
+	p.updated = time.Now()
+}
+
+// Mapper return the mapper the device belongs to.
+func (d *Device) Mapper() *Mapper {
+	return d.mapper
+}
+
+// initProperties build the properties of the device and parse their visitor configs.
+func (d *Device) initProperties() error {
+	properties := make(map[string]*Property)
+	for i := 0; i < len(d.Instance.PropertyVisitors); i++ {
+		prop, err := d.newProperty(&d.Instance.PropertyVisitors[i])
+		if err != nil {
+			return fmt.Errorf("parse visitor of %s failed: %v", d.Instance.PropertyVisitors[i].PropertyName, err)
+		}
+		properties[prop.Name] = prop
+	}
+	d.mu.Lock()
+	d.Properties = properties
+	d.mu.Unlock()
+	return d.initAttributes()
+}
+
+// properties return the properties of the device, for the readers outside of the device
+// which may run while it starts.
+func (d *Device) properties() map[string]*Property {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.Properties
+}
+
+// newProperty build the property of the visitor and parse its visitor config.
+func (d *Device) newProperty(visitor *common.PropertyVisitor) (*Property, error) {
+	prop := &Property{Name: visitor.PropertyName,
+		DataType: visitor.PProperty.DataType,
+		Visitor:  visitor}
+	for j := 0; j < len(d.Instance.Twins); j++ {
+		if d.Instance.Twins[j].PropertyName == prop.Name {
+			prop.ReportTo = ReportToTwin
+			if prop.DataType == "" {
+				prop.DataType = d.Instance.Twins[j].Desired.Metadatas.Type
+			}
+			break
+		}
+	}
+	if prop.ReportTo == "" {
+		for j := 0; j < len(d.Instance.Datas.Properties); j++ {
+			if d.Instance.Datas.Properties[j].PropertyName == prop.Name {
+				prop.ReportTo = ReportToData
+				if prop.DataType == "" {
+					prop.DataType = d.Instance.Datas.Properties[j].Metadatas.Type
+				}
+				break
+			}
+		}
+	}
+	if prop.DataType == "" {
+		prop.DataType = "string"
+	}
+
+	config, err := parseVisitorConfig(visitor)
+	if err != nil {
+		return nil, err
+	}
+	prop.Report = config.ReportConfig
+	if prop.aggregator, err = newAggregator(visitor, prop.Report); err != nil {
+		return nil, err
+	}
+	if prop.validator, err = newValidator(prop, config.ValidateConfig); err != nil {
+		return nil, err
+	}
+	if err = prop.initUnit(config.UnitConfig); err != nil {
+		return nil, err
+	}
+	if err = prop.initExpressions(config.ExpressionConfig); err != nil {
+		return nil, err
+	}
+	if err = d.mapper.Driver.ParseVisitor(d, prop); err != nil {
+		return nil, err
+	}
+	return prop, nil
+}
+
+// instanceProfile return the profile of a device instance.
+func instanceProfile(instance common.DeviceInstance) []byte {
+	profile, err := json.Marshal(instance)
+	if err != nil {
+		klog.Errorf("Marshal instance %v failed: %v", instance.ID, err)
+	}
+	return profile
+}
+
+// start start the device. The device stops when the context is cancelled. A device
+// which is invalid or fails to start is reported in the ERROR state with the reason.
+func (d *Device) start(ctx context.Context) {
+	d.ctx, d.cancel = context.WithCancel(ctx)
+	if err := d.Err(); err != nil {
+		d.fail(err)
+		return
+	}
+	if err := d.initProperties(); err != nil {
+		d.fail(err)
+		return
+	}
+
+	if err := d.mapper.Driver.Connect(d); err != nil {
+		d.fail(fmt.Errorf("connect: %v", err))
+		return
+	}
+
+	if subscriber, ok := d.mapper.Driver.(Subscriber); ok {
+		if err := subscriber.Subscribe(d); err != nil {
+			d.fail(fmt.Errorf("subscribe device: %v", err))
+			return
+		}
+	}
+
+	d.initTwin()
+	d.initCollect()
+	d.initReport()
+
+	if err := d.mapper.initSubscribeMqtt(d.Instance.ID); err != nil {
+		d.fail(fmt.Errorf("subscribe mqtt: %v", err))
+		return
+	}
+	d.syncTwin()
+
+	d.readAttributes()
+	d.initAttributeTimers()
+	d.initGetStatus()
+	d.setStarted(true)
+	klog.V(1).Info(d.Instance.ID, " start successfully")
+}
+
+// fail record why the device failed to start, and publish the ERROR state with the reason.
+// The timers and writes started before the failure are stopped.
+func (d *Device) fail(err error) {
+	klog.Errorf("%v start fail: %v", d.Instance.ID, err)
+	d.mu.Lock()
+	d.err = err
+	d.mu.Unlock()
+	if d.cancel != nil {
+		d.cancel()
+		ctx, cancel := context.WithTimeout(context.Background(), d.mapper.ShutdownTimeout)
+		defer cancel()
+		if !d.wait(ctx) {
+			klog.Errorf("Pending writes of %v are abandoned", d.Instance.ID)
+		}
+	}
+	d.publishError(err.Error())
+}
+
+// Err return why the device is invalid or failed to start, nil if it didn't fail.
+func (d *Device) Err() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.err
+}
+
+// stop stop the timers of the device and wait for the pending writes until the context
+// is done. Then the final state is published if it is set, the Mqtt topics of the device
+// are unsubscribed and the driver client is closed.
+func (d *Device) stop(ctx context.Context, finalState string) {
+	d.setStarted(false)
+	if d.cancel != nil {
+		d.cancel()
+	}
+	if !d.wait(ctx) {
+		klog.Errorf("Pending writes of %v are abandoned", d.Instance.ID)
+	}
+
+	d.flushAll()
+	if finalState != "" {
+		d.publishState(finalState)
+	}
+	if err := d.mapper.unsubscribeMqtt(d.Instance.ID); err != nil {
+		klog.Errorf("Unsubscribe mqtt of %v error: %v", d.Instance.ID, err)
+	}
+	if d.Client != nil {
+		if err := d.mapper.Driver.Close(d); err != nil {
+			klog.Errorf("Close %v error: %v", d.Instance.ID, err)
+		}
+	}
+	klog.V(1).Info(d.Instance.ID, " stopped")
+}
+
+// wait wait for the timers and writes of the device until the context is done. It return
+// false if they didn't finish in time.
+func (d *Device) wait(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Context return the context of the device, which is cancelled when the device stops.
+// Drivers use it to stop their goroutines and the waits of the device.
+func (d *Device) Context() context.Context {
+	if d.ctx == nil {
+		return context.Background()
+	}
+	return d.ctx
+}
+
+// Started return whether the device has started and isn't stopped.
+func (d *Device) Started() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.started
+}
+
+// setStarted record whether the device has started.
+func (d *Device) setStarted(started bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.started = started
+}
+
+// timerStates return the states of the running timers of the device.
+func (d *Device) timerStates() []*timerState {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]*timerState(nil), d.timers...)
+}
+
+// polled return whether the properties of the device are polled.
+func (d *Device) polled() bool {
+	_, ok := d.mapper.Driver.(Subscriber)
+	return !ok
+}
+
+// startTimer run the function periodically until the device stops. The timer is named
+// for the liveness check, which fails if the function gets stuck.
+func (d *Device) startTimer(name string, function func(), cycle time.Duration) {
+	ts := &timerState{name: name, cycle: cycle}
+	d.mu.Lock()
+	d.timers = append(d.timers, ts)
+	d.mu.Unlock()
+
+	run := func() {
+		ts.begin()
+		defer ts.end()
+		function()
+	}
+	timer := common.Timer{Function: run, Duration: cycle, Times: 0, Done: d.ctx.Done()}
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		timer.Start()
+	}()
+}
+
+// goSetTwin write the twin in background. The device waits for the write when it stops.
+func (d *Device) goSetTwin(twin *common.Twin, value string, previous string, version *common.TwinVersion) {
+	if d.ctx == nil || d.ctx.Err() != nil {
+		klog.Warningf("%v is stopped, drop the write of %v", d.Instance.ID, twin.PropertyName)
+		return
+	}
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.setTwin(twin, value, previous, version)
+	}()
+}
+
+// collectCycle return the collect cycle of the property, 1 second if it is not set.
+func collectCycle(prop *Property) time.Duration {
+	collectCycle := time.Duration(prop.Visitor.CollectCycle) * time.Millisecond //time.Duration is nanosecond
+	// If the collect cycle is not set, set it to 1 second.
+	if collectCycle == 0 {
+		collectCycle = 1 * time.Second
+	}
+	return collectCycle
+}
+
+// initTwin write the desired values to the device. A writable twin without desired value
+// is set to the default value of the property when the device starts for the first time.
+func (d *Device) initTwin() {
+	for i := 0; i < len(d.Instance.Twins); i++ {
+		twin := &d.Instance.Twins[i]
+		prop, ok := d.Properties[twin.PropertyName]
+		if !ok {
+			continue
+		}
+		if prop.AccessMode() != common.AccessModeReadOnly {
+			if desired := d.desiredValue(twin); desired != "" {
+				d.goSetTwin(twin, desired, "", nil)
+			} else if value, ok := prop.DefaultValue(); ok && d.mapper.firstStart(d, prop) {
+				klog.V(1).Infof("Set %s of %s to the default value %s", prop.Name, d.Instance.ID, value)
+				d.goSetTwin(twin, value, "", nil)
+			}
+		}
+
+	}
+}
+
+// initGetStatus start timer to get device status and send to eventbus.
+func (d *Device) initGetStatus() {
+	getStatus := GetStatus{Device: d}
+	d.startTimer("status", getStatus.Run, 1*time.Second)
+}
+
+// PublishState publish the device status at once.
+func (d *Device) PublishState() {
+	getStatus := GetStatus{Device: d}
+	getStatus.Run()
+}
+
+// desiredValue return the desired value of the twin, which the twin deltas change.
+func (d *Device) desiredValue(twin *common.Twin) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return twin.Desired.Value
+}
+
+// applyDesired write the desired value of the twin received from the cloud with its
+// version, if it changed. It return false if the device has no such twin.
+func (d *Device) applyDesired(name string, value string, version *common.TwinVersion) bool {
+	for i := 0; i < len(d.Instance.Twins); i++ {
+		twin := &d.Instance.Twins[i]
+		if twin.PropertyName != name {
+			continue
+		}
+		d.mu.Lock()
+		// Desired value is not changed.
+		if twin.Desired.Value == value {
+			d.mu.Unlock()
+			return true
+		}
+		previous := twin.Desired.Value
+		twin.Desired.Value = value
+		d.mu.Unlock()
+		// The write may wait for the device to answer on this connection,
+		// so it must not block the message handler.
+		d.goSetTwin(twin, value, previous, version)
+		return true
+	}
+	return false
+}
+
+// setTwin write the value of the twin to the device. If the write fails, the desired value
+// is reset to previous, so the same value is written again when the cloud sends it. The
+// values of read only twins, invalid values and the values the device didn't apply are
+// rejected with an error in the twin.
+// As a write only twin isn't read, the written value is reported as its actual value.
+// The version of a written value is recorded, so older desired values are dropped.
+func (d *Device) setTwin(twin *common.Twin, value string, previous string, version *common.TwinVersion) {
+	prop, ok := d.Properties[twin.PropertyName]
+	if !ok {
+		d.mapper.metrics.observeDelta(d, twin.PropertyName, deltaRejected)
+		return
+	}
+
+	if err := d.Write(prop, value); err != nil {
+		klog.Errorf("Set %s of %s error: %v", twin.PropertyName, d.Instance.ID, err)
+		d.mapper.metrics.observeDelta(d, twin.PropertyName, deltaRejected)
+		d.mu.Lock()
+		if twin.Desired.Value == value {
+			twin.Desired.Value = previous
+		}
+		d.mu.Unlock()
+		if err == ErrReadOnly || errors.Is(err, ErrInvalidValue) || errors.Is(err, ErrNotApplied) {
+			if err = d.publishTwinError(prop, err); err != nil {
+				klog.Error(err)
+			}
+		}
+	} else {
+		d.mapper.metrics.observeDelta(d, twin.PropertyName, deltaApplied)
+		d.mapper.recordVersion(d, twin.PropertyName, version)
+		if prop.AccessMode() == common.AccessModeWriteOnly {
+			prop.setValue(value)
+			if err = d.publish(ReportToTwin, prop, prop.DataType, value); err != nil {
+				klog.Error(err)
+			}
+		}
+	}
+	d.PublishState()
+}
+
+// Read read the value of the property from the device, unless the property is write only.
+func (d *Device) Read(prop *Property) (string, error) {
+	if prop.AccessMode() == common.AccessModeWriteOnly {
+		return "", ErrWriteOnly
+	}
+	start := time.Now()
+	value, err := d.mapper.Driver.ReadProperty(d, prop)
+	if err == nil {
+		value, err = prop.fromDevice(value)
+	}
+	d.mapper.metrics.observeRead(d, prop, start, err)
+	if err != nil {
+		return "", err
+	}
+	prop.setValue(value)
+	return value, nil
+}
+
+// Write write the value of the property to the device, unless the property is read only
+// or the value is not valid for the property.
+func (d *Device) Write(prop *Property, value string) error {
+	if prop.AccessMode() == common.AccessModeReadOnly {
+		return ErrReadOnly
+	}
+	if prop.validator != nil {
+		if err := prop.validator.validate(value); err != nil {
+			return err
+		}
+	}
+	value, err := prop.toDevice(value)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidValue, err)
+	}
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+
+	start := time.Now()
+	err = d.mapper.Driver.WriteProperty(d, prop, value)
+	d.mapper.metrics.observeWrite(d, prop, start, err)
+	return err
+}
+
+// Report publish a value pushed by the device to twin or data, as ReportTo of the property says.
+func (d *Device) Report(prop *Property, value string) error {
+	if prop.ReportTo == "" || prop.AccessMode() == common.AccessModeWriteOnly {
+		return nil
+	}
+	value, err := prop.fromDevice(value)
+	if err != nil {
+		return fmt.Errorf("report %s failed: %v", prop.Name, err)
+	}
+	if _, err = common.Convert(prop.DataType, value); err != nil {
+		return fmt.Errorf("report %s failed: %v", prop.Name, err)
+	}
+	prop.setValue(value)
+	if err := d.collect(nil, prop.ReportTo, prop, value); err != nil {
+		return err
+	}
+	klog.V(1).Infof("Update the %s value as %s", prop.Name, value)
+	return nil
+}
+
+// publish publish a property value of the data type to twin or data.
+func (d *Device) publish(reportTo string, prop *Property, dataType string, value string) error {
+	var topic string
+	var payload []byte
+	var err error
+	if reportTo == ReportToData {
+		topic = fmt.Sprintf(common.TopicDataUpdate, d.Instance.ID)
+		if payload, err = common.CreateMessageData(prop.Name, dataType, value); err != nil {
+			return fmt.Errorf("create message data failed: %v", err)
+		}
+	} else {
+		topic = fmt.Sprintf(common.TopicTwinUpdate, d.Instance.ID)
+		if payload, err = common.CreateMessageTwinUpdate(prop.Name, dataType, value); err != nil {
+			return fmt.Errorf("create message twin update failed: %v", err)
+		}
+	}
+
+	err = d.mapper.MqttClient.Publish(topic, payload)
+	d.mapper.metrics.observePublish(d, reportTo, err)
+	if err != nil {
+		return fmt.Errorf("publish topic %v failed, err: %v", topic, err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+// fakeDriver is a polled driver keeping the property values in memory.
+type fakeDriver struct {
+	values map[string]string
+	// writeErr is returned by the writes if it is set.
+	writeErr error
+}
+
+func (d *fakeDriver) ParseVisitor(dev *Device, prop *Property) error {
+	prop.Config = string(prop.Visitor.VisitorConfig)
+	return nil
+}
+
+func (d *fakeDriver) Connect(dev *Device) error {
+	dev.Client = d
+	return nil
+}
+
+func (d *fakeDriver) ReadProperty(dev *Device, prop *Property) (string, error) {
+	return d.values[prop.Name], nil
+}
+
+func (d *fakeDriver) WriteProperty(dev *Device, prop *Property, value string) error {
+	if d.writeErr != nil {
+		return d.writeErr
+	}
+	d.values[prop.Name] = value
+	return nil
+}
+
+func (d *fakeDriver) GetStatus(dev *Device) string {
+	return common.DEVSTOK
+}
+
+func (d *fakeDriver) Close(dev *Device) error {
+	return nil
+}
+
+func TestInitProperties(t *testing.T) {
+	instance := common.DeviceInstance{ID: "sensor",
+		PropertyVisitors: []common.PropertyVisitor{
+			{PropertyName: "temperature", PProperty: common.Property{DataType: "int"}},
+			{PropertyName: "humidity"},
+			{PropertyName: "name"}},
+		Twins: []common.Twin{{PropertyName: "temperature"}},
+		Datas: common.Data{Properties: []common.DataProperty{{PropertyName: "humidity",
+			Metadatas: common.DataMetadata{Type: "double"}}}}}
+
+	m := NewMapper(&fakeDriver{values: make(map[string]string)}, nil)
+	dev := &Device{Instance: instance, mapper: m}
+	assert.Nil(t, dev.initProperties())
+	assert.Equal(t, 3, len(dev.Properties))
+
+	assert.Equal(t, ReportToTwin, dev.Properties["temperature"].ReportTo)
+	assert.Equal(t, "int", dev.Properties["temperature"].DataType)
+	assert.Equal(t, ReportToData, dev.Properties["humidity"].ReportTo)
+	assert.Equal(t, "double", dev.Properties["humidity"].DataType)
+	assert.Equal(t, "", dev.Properties["name"].ReportTo)
+	assert.Equal(t, "string", dev.Properties["name"].DataType)
+	assert.True(t, dev.polled())
+}
+
+func TestAccessMode(t *testing.T) {
+	driver := &fakeDriver{values: map[string]string{"pressure": "3", "valve": "1"}}
+	m := NewMapper(driver, nil)
+	instance := common.DeviceInstance{ID: "pump",
+		PropertyVisitors: []common.PropertyVisitor{
+			{PropertyName: "pressure", PProperty: common.Property{DataType: "int", AccessMode: common.AccessModeReadOnly}},
+			{PropertyName: "valve", PProperty: common.Property{DataType: "int", AccessMode: common.AccessModeWriteOnly}},
+			{PropertyName: "speed", PProperty: common.Property{DataType: "int"}}}}
+	dev := &Device{Instance: instance, mapper: m}
+	assert.Nil(t, dev.initProperties())
+
+	assert.Equal(t, common.AccessModeReadWrite, dev.Properties["speed"].AccessMode())
+	_, err := dev.Read(dev.Properties["pressure"])
+	assert.Nil(t, err)
+	assert.Equal(t, ErrReadOnly, dev.Write(dev.Properties["pressure"], "4"))
+	_, err = dev.Read(dev.Properties["valve"])
+	assert.Equal(t, ErrWriteOnly, err)
+	assert.Nil(t, dev.Write(dev.Properties["valve"], "0"))
+	assert.Equal(t, "0", driver.values["valve"])
+}
+
+func TestDefaultValue(t *testing.T) {
+	tests := []struct {
+		defaultValue interface{}
+		value        string
+		ok           bool
+	}{
+		{nil, "", false},
+		{"", "", false},
+		{"off", "off", true},
+		{float64(20), "20", true},
+		{2.5, "2.5", true},
+		{true, "true", true},
+	}
+	for _, test := range tests {
+		prop := &Property{Visitor: &common.PropertyVisitor{PProperty: common.Property{DefaultValue: test.defaultValue}}}
+		value, ok := prop.DefaultValue()
+		assert.Equal(t, test.value, value)
+		assert.Equal(t, test.ok, ok)
+	}
+
+	m := NewMapper(&fakeDriver{}, nil)
+	dev := &Device{Instance: common.DeviceInstance{ID: "pump"}, mapper: m}
+	prop := &Property{Name: "valve"}
+	assert.True(t, m.firstStart(dev, prop))
+	assert.False(t, m.firstStart(dev, prop))
+}
+
+func TestSetTwinNotApplied(t *testing.T) {
+	driver := &fakeDriver{values: make(map[string]string),
+		writeErr: fmt.Errorf("%w: no acknowledgement", ErrNotApplied)}
+	// The client isn't connected, its publishes fail at once.
+	m := NewMapper(driver, &common.MqttClient{Client: mqtt.NewClient(mqtt.NewClientOptions())})
+	dev := &Device{Instance: common.DeviceInstance{ID: "sensor",
+		PropertyVisitors: []common.PropertyVisitor{{PropertyName: "switch",
+			PProperty: common.Property{DataType: "string", AccessMode: common.AccessModeReadWrite}}},
+		Twins: []common.Twin{{PropertyName: "switch", Desired: common.DesiredData{Value: "on"}}}}, mapper: m}
+	assert.Nil(t, dev.initProperties())
+
+	dev.setTwin(&dev.Instance.Twins[0], "on", "off", &common.TwinVersion{CloudVersion: 2})
+	assert.Equal(t, "off", dev.desiredValue(&dev.Instance.Twins[0]))
+	// The failure is reported in the twin of the property.
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.metrics.publishes.WithLabelValues("sensor", ReportToTwin, resultFailure)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.metrics.twinDeltas.WithLabelValues("sensor", "switch", deltaRejected)))
+	// The version of the failed write isn't recorded, its retry isn't stale.
+	assert.Empty(t, m.versions)
+}
+
+func TestStartFailStopsTimers(t *testing.T) {
+	// The client isn't connected, the device fails to subscribe after its timers started.
+	m := NewMapper(&fakeDriver{values: map[string]string{"temperature": "21"}},
+		&common.MqttClient{Client: mqtt.NewClient(mqtt.NewClientOptions())})
+	dev := &Device{Instance: common.DeviceInstance{ID: "sensor",
+		PropertyVisitors: []common.PropertyVisitor{{PropertyName: "temperature",
+			PProperty: common.Property{DataType: "int", AccessMode: common.AccessModeReadOnly}}},
+		Twins: []common.Twin{{PropertyName: "temperature"}}}, mapper: m}
+	dev.start(context.Background())
+
+	assert.Contains(t, dev.Err().Error(), "subscribe mqtt")
+	assert.False(t, dev.Started())
+	assert.Equal(t, context.Canceled, dev.Context().Err())
+	assert.True(t, dev.wait(context.Background()))
+}
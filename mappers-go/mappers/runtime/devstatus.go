@@ -0,0 +1,74 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"fmt"
+
+	"k8s.io/klog/v2"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+// GetStatus is the timer structure for getting device status.
+type GetStatus struct {
+	Device *Device
+	Status string
+}
+
+// Run timer function.
+func (gs *GetStatus) Run() {
+	gs.Status = gs.Device.mapper.Driver.GetStatus(gs.Device)
+	gs.Device.publishState(gs.Status)
+}
+
+// publishState publish the state of the device, with its attributes if they changed since
+// they were last published.
+func (d *Device) publishState(state string) {
+	attributes := d.changedAttributes()
+	var payload []byte
+	var err error
+	if payload, err = common.CreateMessageDeviceUpdate(state, attributes); err != nil {
+		klog.Errorf("Create message state failed: %v", err)
+		return
+	}
+	topic := fmt.Sprintf(common.TopicStateUpdate, d.Instance.ID)
+	err = d.mapper.MqttClient.Publish(topic, payload)
+	d.mapper.metrics.observePublish(d, publishKindState, err)
+	if err != nil {
+		klog.Errorf("Publish failed: %v", err)
+		if attributes != nil {
+			d.setAttributesChanged()
+		}
+		return
+	}
+}
+
+// publishError publish the ERROR state of the device with the reason.
+func (d *Device) publishError(reason string) {
+	payload, err := common.CreateMessageStateError(reason)
+	if err != nil {
+		klog.Errorf("Create message state failed: %v", err)
+		return
+	}
+	topic := fmt.Sprintf(common.TopicStateUpdate, d.Instance.ID)
+	err = d.mapper.MqttClient.Publish(topic, payload)
+	d.mapper.metrics.observePublish(d, publishKindState, err)
+	if err != nil {
+		klog.Errorf("Publish failed: %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+// Driver is implemented by each mapper to access the devices of its protocol.
+// The runtime parses the configmap, dispatches twin deltas, runs the timers and
+// publishes to edgecore, the driver only talks to the devices.
+type Driver interface {
+	// ParseVisitor parses the visitor config of a property into Property.Config.
+	// It is called once for each property and dynamic attribute before the device connects.
+	ParseVisitor(dev *Device, prop *Property) error
+	// Connect connects to the device and keeps the client in Device.Client.
+	Connect(dev *Device) error
+	// ReadProperty reads the value of a property or a dynamic attribute from the device.
+	ReadProperty(dev *Device, prop *Property) (string, error)
+	// WriteProperty writes the value of a property to the device.
+	WriteProperty(dev *Device, prop *Property, value string) error
+	// GetStatus returns the device status, one of the common.DEVST* constants.
+	GetStatus(dev *Device) string
+	// Close closes the client of the device.
+	Close(dev *Device) error
+}
+
+// Subscriber is implemented by drivers whose devices push their property values
+// instead of being polled. Subscribe is called after Connect, and again when the mapper
+// reconnects to edgecore. The driver reports the values by Device.Report. The properties
+// of such devices are not polled.
+type Subscriber interface {
+	Subscribe(dev *Device) error
+}
+
+// SchemaDriver is implemented by drivers to have the protocol and visitor configs of the
+// device profile checked against the types they decode into, see Mapper.Validate.
+type SchemaDriver interface {
+	// ProtocolSchema returns pointers to the types the protocolConfig and the
+	// protocolCommonConfig of a protocol decode into.
+	ProtocolSchema() (config interface{}, commonConfig interface{})
+	// VisitorSchema returns a pointer to the type the visitor config decodes into.
+	// The runtime configs are allowed in its configData as well.
+	VisitorSchema() interface{}
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"fmt"
+
+	"k8s.io/klog/v2"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+// initExpressions compile the read and write expressions of the expression config. A
+// writable property with a read expression but no write expression can't be written.
+func (p *Property) initExpressions(config common.ExpressionConfig) error {
+	var err error
+	if config.ReadExpression != "" {
+		if p.readExpression, err = common.CompileExpression(config.ReadExpression, "raw"); err != nil {
+			return fmt.Errorf("invalid readExpression: %v", err)
+		}
+	}
+	if config.WriteExpression != "" {
+		if p.writeExpression, err = common.CompileExpression(config.WriteExpression, "value"); err != nil {
+			return fmt.Errorf("invalid writeExpression: %v", err)
+		}
+	}
+	if p.readExpression != nil && p.writeExpression == nil && p.AccessMode() != common.AccessModeReadOnly {
+		klog.Warningf("Property %s has a readExpression but no writeExpression, it can't be written", p.Name)
+	}
+	return nil
+}
+
+// fromDevice transform a value read from the device into a value of the property, by the
+// read expression and then the unit conversion.
+func (p *Property) fromDevice(value string) (string, error) {
+	if p.readExpression != nil {
+		var err error
+		if value, err = p.readExpression.Evaluate(value, p.DataType); err != nil {
+			return "", err
+		}
+	}
+	return p.fromDeviceUnit(value)
+}
+
+// toDevice transform a value of the property into the value written to the device, by the
+// unit conversion and then the write expression.
+func (p *Property) toDevice(value string) (string, error) {
+	value, err := p.toDeviceUnit(value)
+	if err != nil {
+		return "", err
+	}
+	if p.writeExpression != nil {
+		return p.writeExpression.Evaluate(value, "")
+	}
+	if p.readExpression != nil {
+		return "", fmt.Errorf("property %s has no writeExpression", p.Name)
+	}
+	return value, nil
+}
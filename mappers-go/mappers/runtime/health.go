@@ -0,0 +1,158 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+// Paths of the liveness and readiness endpoints.
+const (
+	livenessPath  = "/healthz"
+	readinessPath = "/readyz"
+)
+
+// DefaultStuckTimeout is the default StuckTimeout of a mapper.
+const DefaultStuckTimeout = time.Minute
+
+// timerState is the state of a timer of a device, to find the timers stuck in their function.
+type timerState struct {
+	name  string
+	cycle time.Duration
+
+	mu sync.Mutex
+	// busySince is when the running function started, zero if the function isn't running.
+	busySince time.Time
+}
+
+// begin record the function of the timer starts.
+func (ts *timerState) begin() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.busySince = time.Now()
+}
+
+// end record the function of the timer returns.
+func (ts *timerState) end() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.busySince = time.Time{}
+}
+
+// stuck return how long the function of the timer runs for, if it is longer than its
+// cycle and the timeout.
+func (ts *timerState) stuck(timeout time.Duration) (time.Duration, bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.busySince.IsZero() {
+		return 0, false
+	}
+	busy := time.Since(ts.busySince)
+	return busy, busy > ts.cycle+timeout
+}
+
+// mqttConnected return whether the mapper is connected to the edgecore broker.
+func (m *Mapper) mqttConnected() bool {
+	return m.MqttClient != nil && m.MqttClient.Client != nil && m.MqttClient.Client.IsConnected()
+}
+
+// live return why the mapper is not alive, nothing if it is alive. The mapper is not
+// alive when a timer function of a device runs longer than its cycle and StuckTimeout.
+func (m *Mapper) live() []string {
+	var reasons []string
+	for _, dev := range m.Devices() {
+		for _, ts := range dev.timerStates() {
+			if busy, stuck := ts.stuck(m.StuckTimeout); stuck {
+				reasons = append(reasons, fmt.Sprintf("timer %s of device %s is stuck for %v",
+					ts.name, dev.Instance.ID, busy.Round(time.Second)))
+			}
+		}
+	}
+	sort.Strings(reasons)
+	return reasons
+}
+
+// ready return why the mapper is not ready, nothing if it is ready. The mapper is ready
+// when the configmap is parsed, the edgecore broker is connected and all devices are
+// started, except the devices which failed in the ERROR state. If ReadyMaxDisconnected
+// is set, no more than that percentage of the devices may be disconnected.
+func (m *Mapper) ready() []string {
+	var reasons []string
+	m.mu.Lock()
+	parsed := m.path != ""
+	m.mu.Unlock()
+	if !parsed {
+		reasons = append(reasons, "configmap is not parsed")
+	}
+	if !m.mqttConnected() {
+		reasons = append(reasons, "mqtt broker is not connected")
+	}
+
+	devices := m.Devices()
+	disconnected := 0
+	var stopped []string
+	for _, dev := range devices {
+		if dev.Err() != nil {
+			continue
+		}
+		if !dev.Started() {
+			stopped = append(stopped, dev.Instance.ID)
+			continue
+		}
+		if m.Driver.GetStatus(dev) == common.DEVSTDISCONN {
+			disconnected++
+		}
+	}
+	if len(stopped) > 0 {
+		sort.Strings(stopped)
+		reasons = append(reasons, "devices are not started: "+strings.Join(stopped, ", "))
+	}
+	if m.ReadyMaxDisconnected > 0 && len(devices) > 0 && disconnected*100 > m.ReadyMaxDisconnected*len(devices) {
+		reasons = append(reasons, fmt.Sprintf("%d of %d devices are disconnected, more than %d%%",
+			disconnected, len(devices), m.ReadyMaxDisconnected))
+	}
+	return reasons
+}
+
+// healthHandler return the handler of a health check, which answers 200 if the check
+// passes, or 503 with the reasons.
+func healthHandler(name string, check func() []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		reasons := check()
+		if len(reasons) == 0 {
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("ok\n"))
+			return
+		}
+		klog.Warningf("%s check failed: %s", name, strings.Join(reasons, "; "))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(strings.Join(reasons, "\n") + "\n"))
+	})
+}
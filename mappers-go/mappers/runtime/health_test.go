@@ -0,0 +1,54 @@
+package runtime
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+func TestReady(t *testing.T) {
+	m := NewMapper(&fakeDriver{values: make(map[string]string)}, nil)
+	m.devices["sensor"] = &Device{Instance: common.DeviceInstance{ID: "sensor"}, mapper: m}
+	assert.Equal(t, []string{"configmap is not parsed",
+		"mqtt broker is not connected",
+		"devices are not started: sensor"}, m.ready())
+
+	m.path = "deviceProfile.json"
+	m.devices["sensor"].setStarted(true)
+	assert.Equal(t, []string{"mqtt broker is not connected"}, m.ready())
+}
+
+func TestLive(t *testing.T) {
+	m := NewMapper(&fakeDriver{values: make(map[string]string)}, nil)
+	dev := &Device{Instance: common.DeviceInstance{ID: "sensor"}, mapper: m}
+	m.devices["sensor"] = dev
+	ts := &timerState{name: "temperature", cycle: time.Second}
+	dev.timers = append(dev.timers, ts)
+	assert.Empty(t, m.live())
+
+	ts.begin()
+	assert.Empty(t, m.live())
+	ts.busySince = time.Now().Add(-2 * time.Minute)
+	assert.Equal(t, []string{"timer temperature of device sensor is stuck for 2m0s"}, m.live())
+	ts.end()
+	assert.Empty(t, m.live())
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	server, _ := newTestServer(t)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + livenessPath)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Get(server.URL + readinessPath)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
@@ -0,0 +1,358 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"k8s.io/klog/v2"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+// Mapper runs the devices in the configmap with the driver of the mapper.
+type Mapper struct {
+	Driver Driver
+	// MqttClient is the connection to the edgecore broker.
+	MqttClient *common.MqttClient
+	// LocalTest uses the edgecore topics without the leading "$".
+	LocalTest bool
+	// HTTPAddress is the address of the embedded HTTP server, which is disabled if it is empty.
+	HTTPAddress string
+	// HTTPToken is the bearer token of the device API, which is disabled if it is empty.
+	HTTPToken string
+	// ShutdownTimeout is how long the devices are given to finish their writes when
+	// the mapper stops.
+	ShutdownTimeout time.Duration
+	// StuckTimeout is how much longer than its cycle a timer function may run before
+	// the liveness check fails.
+	StuckTimeout time.Duration
+	// ReadyMaxDisconnected is the percentage of the devices which may be disconnected
+	// while the mapper is ready, 0 to not check it.
+	ReadyMaxDisconnected int
+	// Strict fails Init and the reloads on any problem of the device profile, see Validate.
+	// Otherwise the invalid devices are reported in the ERROR state and the others run.
+	Strict bool
+
+	// ctx is the context the mapper runs with, the devices stop when it is cancelled.
+	ctx context.Context
+	mu  sync.Mutex
+	// path is the configmap file, profile is the content of it the devices run with.
+	path      string
+	profile   []byte
+	devices   map[string]*Device
+	models    map[string]common.DeviceModel
+	protocols map[string]common.Protocol
+	wg        sync.WaitGroup
+	metrics   *metrics
+	// defaulted are the properties set to their default value, by device ID and property name.
+	defaulted map[string]map[string]bool
+	// versions are the cloud versions of the last desired values applied, by device ID and
+	// property name.
+	versions map[string]map[string]int64
+}
+
+// DefaultShutdownTimeout is the default ShutdownTimeout of a mapper.
+const DefaultShutdownTimeout = 10 * time.Second
+
+// NewMapper allocate and return a mapper with the driver.
+func NewMapper(driver Driver, mqttClient *common.MqttClient) *Mapper {
+	m := &Mapper{Driver: driver,
+		MqttClient:      mqttClient,
+		ShutdownTimeout: DefaultShutdownTimeout,
+		StuckTimeout:    DefaultStuckTimeout,
+		ctx:             context.Background(),
+		devices:         make(map[string]*Device),
+		models:          make(map[string]common.DeviceModel),
+		protocols:       make(map[string]common.Protocol),
+		defaulted:       make(map[string]map[string]bool),
+		versions:        make(map[string]map[string]int64)}
+	m.metrics = newMetrics(m)
+	return m
+}
+
+// Init initialize the device datas from the configmap.
+func (m *Mapper) Init(configmapPath string) error {
+	if m.Strict {
+		if err := m.Validate(configmapPath); err != nil {
+			return err
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	profile, err := ioutil.ReadFile(configmapPath)
+	if err != nil {
+		return err
+	}
+	if err = Parse(configmapPath, m.devices, m.models, m.protocols); err != nil {
+		return err
+	}
+	m.path = configmapPath
+	m.profile = profile
+	for _, dev := range m.devices {
+		dev.mapper = m
+		dev.profile = instanceProfile(dev.Instance)
+	}
+	return nil
+}
+
+// Start start all devices and block until the context is cancelled. The devices are
+// updated when the configmap changes. When the context is cancelled, the devices are
+// stopped gracefully and the connection to edgecore is closed.
+func (m *Mapper) Start(ctx context.Context) {
+	m.mu.Lock()
+	m.ctx = ctx
+	m.mu.Unlock()
+	if m.MqttClient != nil {
+		m.MqttClient.OnReconnect(m.resync)
+	}
+
+	if m.HTTPAddress != "" {
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			m.serve(ctx)
+		}()
+	}
+
+	for _, dev := range m.Devices() {
+		klog.V(4).Info("Dev: ", dev.Instance.ID, dev)
+		dev.start(ctx)
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		if err := m.watch(ctx); err != nil {
+			klog.Errorf("Watch configmap %v failed, it isn't reloaded: %v", m.path, err)
+		}
+	}()
+
+	<-ctx.Done()
+	m.shutdown()
+	m.wg.Wait()
+}
+
+// shutdown stop all devices within ShutdownTimeout. The polling stops at once, then the
+// pending writes are waited for, and the final state of each device is published.
+func (m *Mapper) shutdown() {
+	klog.Info("Mapper is stopping")
+	ctx, cancel := context.WithTimeout(context.Background(), m.ShutdownTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, dev := range m.Devices() {
+		wg.Add(1)
+		go func(dev *Device) {
+			defer wg.Done()
+			dev.stop(ctx, common.DEVSTUNKNOWN)
+		}(dev)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		klog.Errorf("Devices are not stopped in %v", m.ShutdownTimeout)
+	}
+
+	// The signal may come before the edgecore broker is connected.
+	if m.MqttClient != nil && m.MqttClient.Client != nil {
+		m.MqttClient.Disconnect()
+	}
+	klog.Info("Mapper is stopped")
+}
+
+// firstStart return whether the property of the device is set to its default value for
+// the first time. The devices restarted by a configmap reload are not set again.
+func (m *Mapper) firstStart(dev *Device, prop *Property) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.defaulted[dev.Instance.ID][prop.Name] {
+		return false
+	}
+	if m.defaulted[dev.Instance.ID] == nil {
+		m.defaulted[dev.Instance.ID] = make(map[string]bool)
+	}
+	m.defaulted[dev.Instance.ID][prop.Name] = true
+	return true
+}
+
+// acceptVersion return whether the desired value of the twin with the version isn't older
+// than the last one applied. A twin without version is always accepted.
+func (m *Mapper) acceptVersion(dev *Device, name string, twin *common.MsgTwin) bool {
+	if twin == nil || twin.ExpectedVersion == nil {
+		return true
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if last, ok := m.versions[dev.Instance.ID][name]; ok && twin.ExpectedVersion.CloudVersion < last {
+		klog.Warningf("Drop the stale desired value of %s of %s, version %d is older than %d",
+			name, dev.Instance.ID, twin.ExpectedVersion.CloudVersion, last)
+		return false
+	}
+	return true
+}
+
+// recordVersion record the version of the desired value of the twin, once it is written
+// to the device. A twin without version isn't recorded.
+func (m *Mapper) recordVersion(dev *Device, name string, version *common.TwinVersion) {
+	if version == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.versions[dev.Instance.ID] == nil {
+		m.versions[dev.Instance.ID] = make(map[string]int64)
+	}
+	if last, ok := m.versions[dev.Instance.ID][name]; !ok || version.CloudVersion > last {
+		m.versions[dev.Instance.ID][name] = version.CloudVersion
+	}
+}
+
+// forgetDevice drop the state kept for the device, which is removed from the mapper, so a
+// device added again with the same ID starts afresh.
+func (m *Mapper) forgetDevice(id string) {
+	m.mu.Lock()
+	delete(m.defaulted, id)
+	delete(m.versions, id)
+	m.mu.Unlock()
+	m.metrics.removeDevice(id)
+}
+
+// context return the context the mapper runs with.
+func (m *Mapper) context() context.Context {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.ctx
+}
+
+// Device return the device by ID.
+func (m *Mapper) Device(id string) (*Device, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dev, ok := m.devices[id]
+	return dev, ok
+}
+
+// Devices return all devices.
+func (m *Mapper) Devices() []*Device {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	devices := make([]*Device, 0, len(m.devices))
+	for _, dev := range m.devices {
+		devices = append(devices, dev)
+	}
+	return devices
+}
+
+// AddDevice register and start a device which is not in the configmap.
+// If a device with the same ID exists, it is returned instead.
+func (m *Mapper) AddDevice(instance common.DeviceInstance) *Device {
+	m.mu.Lock()
+	if dev, ok := m.devices[instance.ID]; ok {
+		m.mu.Unlock()
+		return dev
+	}
+	dev := &Device{Instance: instance, Dynamic: true, mapper: m}
+	m.devices[instance.ID] = dev
+	m.mu.Unlock()
+
+	dev.start(m.context())
+	return dev
+}
+
+// onMessage callback function of Mqtt subscribe message.
+func (m *Mapper) onMessage(client mqtt.Client, message mqtt.Message) {
+	klog.V(2).Info("Receive message", message.Topic())
+	// Get device ID and get device instance
+	id := common.GetDeviceID(message.Topic())
+	if id == "" {
+		klog.Error("Wrong topic")
+		return
+	}
+	klog.V(2).Info("Device id: ", id)
+
+	dev, ok := m.Device(id)
+	if !ok {
+		klog.Error("Device not exist")
+		return
+	}
+
+	// Get twin map key as the propertyName
+	var delta common.DeviceTwinDelta
+	if err := json.Unmarshal(message.Payload(), &delta); err != nil {
+		klog.Errorf("Unmarshal message failed: %v", err)
+		return
+	}
+	klog.V(2).Infof("Receive message parsed: %v", delta)
+	for twinName, twinValue := range delta.Delta {
+		m.metrics.observeDelta(dev, twinName, deltaReceived)
+		twin := delta.Twin[twinName]
+		if !m.acceptVersion(dev, twinName, twin) {
+			m.metrics.observeDelta(dev, twinName, deltaStale)
+			continue
+		}
+		if !dev.applyDesired(twinName, twinValue, twinVersion(twin)) {
+			klog.Error("Twin not found: ", twinName)
+			m.metrics.observeDelta(dev, twinName, deltaRejected)
+		}
+	}
+}
+
+// Topic return the edgecore topic of the device. The leading "$" is removed for local test.
+func (m *Mapper) Topic(format string, instanceID string) string {
+	if m.LocalTest {
+		format = strings.TrimPrefix(format, "$")
+	}
+	return fmt.Sprintf(format, instanceID)
+}
+
+// unsubscribeMqtt unsubscribe the Mqtt topics of the device from cloudcore.
+func (m *Mapper) unsubscribeMqtt(instanceID string) error {
+	return m.MqttClient.Unsubscribe(m.Topic(common.TopicTwinUpdateDelta, instanceID),
+		m.Topic(common.TopicTwinGetResult, instanceID))
+}
+
+// initSubscribeMqtt subscribe Mqtt topics from cloudcore.
+func (m *Mapper) initSubscribeMqtt(instanceID string) error {
+	topic := m.Topic(common.TopicTwinUpdateDelta, instanceID)
+	klog.V(1).Info("Subscribe topic: ", topic)
+	if err := m.MqttClient.Subscribe(topic, m.onMessage); err != nil {
+		return err
+	}
+	topic = m.Topic(common.TopicTwinGetResult, instanceID)
+	klog.V(1).Info("Subscribe topic: ", topic)
+	return m.MqttClient.Subscribe(topic, m.onTwinResult)
+}
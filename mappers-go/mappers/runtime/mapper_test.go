@@ -0,0 +1,18 @@
+package runtime
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+func TestShutdownNotConnected(t *testing.T) {
+	// The mapper stops before the edgecore broker is connected, without panic.
+	for _, client := range []*common.MqttClient{nil, {}} {
+		m := NewMapper(&fakeDriver{values: make(map[string]string)}, client)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		m.Start(ctx)
+	}
+}
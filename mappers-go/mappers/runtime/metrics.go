@@ -0,0 +1,230 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsNamespace is the namespace of the mapper metrics.
+const metricsNamespace = "mapper"
+
+// metricsPath is the path of the metrics endpoint.
+const metricsPath = "/metrics"
+
+// Results of the publishes and the twin deltas in the metric labels.
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+	deltaReceived = "received"
+	deltaApplied  = "applied"
+	deltaRejected = "rejected"
+	deltaStale    = "stale"
+)
+
+// publishKindState is the kind of the state messages in the metric labels, the
+// property values are twin or data.
+const publishKindState = "state"
+
+// metrics are the Prometheus metrics of a mapper, registered in its own registry.
+type metrics struct {
+	registry *prometheus.Registry
+
+	readDuration  *prometheus.HistogramVec
+	readErrors    *prometheus.CounterVec
+	writeDuration *prometheus.HistogramVec
+	writeErrors   *prometheus.CounterVec
+	publishes     *prometheus.CounterVec
+	twinDeltas    *prometheus.CounterVec
+	timerOverruns *prometheus.CounterVec
+	unchanged     *prometheus.CounterVec
+
+	mqttConnected *prometheus.Desc
+	lastReadAge   *prometheus.Desc
+	mapper        *Mapper
+
+	mu sync.Mutex
+	// series are the label values observed by device ID, to delete them with the device.
+	series map[string]map[deviceSeries]bool
+}
+
+// labelDeleter is a metric vector whose series are deleted by their label values.
+type labelDeleter interface {
+	DeleteLabelValues(lvs ...string) bool
+}
+
+// deviceSeries is a series of a metric vector, with its label values joined by labelSep.
+type deviceSeries struct {
+	vec    labelDeleter
+	labels string
+}
+
+// labelSep joins the label values of a series, it is not valid in a label value.
+const labelSep = "\xff"
+
+// newMetrics create and register the metrics of the mapper.
+func newMetrics(m *Mapper) *metrics {
+	mt := &metrics{registry: prometheus.NewRegistry(),
+		readDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{Namespace: metricsNamespace,
+			Name: "driver_read_duration_seconds",
+			Help: "Latency of the property reads of the driver."}, []string{"device", "property"}),
+		readErrors: prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: metricsNamespace,
+			Name: "driver_read_errors_total",
+			Help: "Number of the property reads of the driver which failed."}, []string{"device", "property"}),
+		writeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{Namespace: metricsNamespace,
+			Name: "driver_write_duration_seconds",
+			Help: "Latency of the property writes of the driver."}, []string{"device", "property"}),
+		writeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: metricsNamespace,
+			Name: "driver_write_errors_total",
+			Help: "Number of the property writes of the driver which failed."}, []string{"device", "property"}),
+		publishes: prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: metricsNamespace,
+			Name: "publishes_total",
+			Help: "Number of the messages published to edgecore, by kind (twin, data or state) and result."},
+			[]string{"device", "kind", "result"}),
+		twinDeltas: prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: metricsNamespace,
+			Name: "twin_deltas_total",
+			Help: "Number of the twin deltas received, applied to, rejected by the devices and dropped as stale."},
+			[]string{"device", "property", "result"}),
+		timerOverruns: prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: metricsNamespace,
+			Name: "timer_overruns_total",
+			Help: "Number of the property collections which took longer than their collect cycle."},
+			[]string{"device", "timer"}),
+		unchanged: prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: metricsNamespace,
+			Name: "unchanged_values_total",
+			Help: "Number of the property values not reported because they didn't change."},
+			[]string{"device", "property"}),
+		mqttConnected: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "mqtt_connected"),
+			"Whether the mapper is connected to the edgecore broker.", nil, nil),
+		lastReadAge: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "property_last_read_age_seconds"),
+			"Time since the last successful read of the property.", []string{"device", "property"}, nil),
+		mapper: m,
+		series: make(map[string]map[deviceSeries]bool)}
+
+	mt.registry.MustRegister(prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		mt.readDuration, mt.readErrors, mt.writeDuration, mt.writeErrors,
+		mt.publishes, mt.twinDeltas, mt.timerOverruns, mt.unchanged, mt)
+	return mt
+}
+
+// Describe implement prometheus.Collector for the metrics computed at scrape time.
+func (mt *metrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- mt.mqttConnected
+	ch <- mt.lastReadAge
+}
+
+// Collect implement prometheus.Collector for the metrics computed at scrape time.
+func (mt *metrics) Collect(ch chan<- prometheus.Metric) {
+	connected := 0.0
+	if mt.mapper.mqttConnected() {
+		connected = 1
+	}
+	ch <- prometheus.MustNewConstMetric(mt.mqttConnected, prometheus.GaugeValue, connected)
+
+	now := time.Now()
+	for _, dev := range mt.mapper.Devices() {
+		for _, prop := range dev.properties() {
+			if _, updated := prop.Value(); !updated.IsZero() {
+				ch <- prometheus.MustNewConstMetric(mt.lastReadAge, prometheus.GaugeValue,
+					now.Sub(updated).Seconds(), dev.Instance.ID, prop.Name)
+			}
+		}
+	}
+}
+
+// track record the label values of a series of the device, the device ID first.
+func (mt *metrics) track(vec labelDeleter, labels ...string) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	series, ok := mt.series[labels[0]]
+	if !ok {
+		series = make(map[deviceSeries]bool)
+		mt.series[labels[0]] = series
+	}
+	series[deviceSeries{vec: vec, labels: strings.Join(labels, labelSep)}] = true
+}
+
+// removeDevice delete the series of the device, which is removed from the mapper.
+func (mt *metrics) removeDevice(id string) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	for series := range mt.series[id] {
+		series.vec.DeleteLabelValues(strings.Split(series.labels, labelSep)...)
+	}
+	delete(mt.series, id)
+}
+
+// observeRead record a read of the driver.
+func (mt *metrics) observeRead(dev *Device, prop *Property, start time.Time, err error) {
+	mt.readDuration.WithLabelValues(dev.Instance.ID, prop.Name).Observe(time.Since(start).Seconds())
+	mt.track(mt.readDuration, dev.Instance.ID, prop.Name)
+	if err != nil {
+		mt.readErrors.WithLabelValues(dev.Instance.ID, prop.Name).Inc()
+		mt.track(mt.readErrors, dev.Instance.ID, prop.Name)
+	}
+}
+
+// observeWrite record a write of the driver.
+func (mt *metrics) observeWrite(dev *Device, prop *Property, start time.Time, err error) {
+	mt.writeDuration.WithLabelValues(dev.Instance.ID, prop.Name).Observe(time.Since(start).Seconds())
+	mt.track(mt.writeDuration, dev.Instance.ID, prop.Name)
+	if err != nil {
+		mt.writeErrors.WithLabelValues(dev.Instance.ID, prop.Name).Inc()
+		mt.track(mt.writeErrors, dev.Instance.ID, prop.Name)
+	}
+}
+
+// observePublish record a message published to edgecore.
+func (mt *metrics) observePublish(dev *Device, kind string, err error) {
+	result := resultSuccess
+	if err != nil {
+		result = resultFailure
+	}
+	mt.publishes.WithLabelValues(dev.Instance.ID, kind, result).Inc()
+	mt.track(mt.publishes, dev.Instance.ID, kind, result)
+}
+
+// observeDelta record a twin delta of the property, received, applied, rejected or stale.
+func (mt *metrics) observeDelta(dev *Device, property string, result string) {
+	mt.twinDeltas.WithLabelValues(dev.Instance.ID, property, result).Inc()
+	mt.track(mt.twinDeltas, dev.Instance.ID, property, result)
+}
+
+// observeOverrun record a collection of the timer longer than its collect cycle.
+func (mt *metrics) observeOverrun(dev *Device, timer string) {
+	mt.timerOverruns.WithLabelValues(dev.Instance.ID, timer).Inc()
+	mt.track(mt.timerOverruns, dev.Instance.ID, timer)
+}
+
+// observeUnchanged record a value of the property not reported because it didn't change.
+func (mt *metrics) observeUnchanged(dev *Device, prop *Property) {
+	mt.unchanged.WithLabelValues(dev.Instance.ID, prop.Name).Inc()
+	mt.track(mt.unchanged, dev.Instance.ID, prop.Name)
+}
+
+// handler return the handler of the metrics endpoint.
+func (mt *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(mt.registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,66 @@
+package runtime
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	server, _ := newTestServer(t)
+	defer server.Close()
+
+	code, _ := request(t, http.MethodGet, server.URL+apiPrefix+"/sensor/properties/temperature", "secret", "")
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = request(t, http.MethodPut, server.URL+apiPrefix+"/sensor/properties/switch", "secret", `{"value":"on"}`)
+	assert.Equal(t, http.StatusOK, code)
+
+	resp, err := http.Get(server.URL + metricsPath)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.Contains(t, string(body), `mapper_driver_read_duration_seconds_count{device="sensor",property="temperature"} 1`)
+	assert.Contains(t, string(body), `mapper_driver_write_duration_seconds_count{device="sensor",property="switch"} 1`)
+	assert.Contains(t, string(body), `mapper_property_last_read_age_seconds{device="sensor",property="temperature"}`)
+	assert.Contains(t, string(body), "mapper_mqtt_connected 0")
+}
+
+func TestMetricsErrors(t *testing.T) {
+	m := NewMapper(&fakeDriver{values: make(map[string]string)}, nil)
+	dev := &Device{mapper: m}
+	dev.Instance.ID = "sensor"
+	prop := &Property{Name: "temperature"}
+
+	m.metrics.observeRead(dev, prop, time.Now(), assert.AnError)
+	m.metrics.observeDelta(dev, prop.Name, deltaReceived)
+	m.metrics.observeDelta(dev, prop.Name, deltaRejected)
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.metrics.readErrors.WithLabelValues("sensor", "temperature")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.metrics.twinDeltas.WithLabelValues("sensor", "temperature", deltaRejected)))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.metrics.twinDeltas.WithLabelValues("sensor", "temperature", deltaApplied)))
+}
+
+func TestMetricsRemoveDevice(t *testing.T) {
+	m := NewMapper(&fakeDriver{values: make(map[string]string)}, nil)
+	sensor := &Device{mapper: m}
+	sensor.Instance.ID = "sensor"
+	pump := &Device{mapper: m}
+	pump.Instance.ID = "pump"
+	prop := &Property{Name: "temperature"}
+
+	m.metrics.observeRead(sensor, prop, time.Now(), assert.AnError)
+	m.metrics.observePublish(sensor, publishKindState, nil)
+	m.metrics.observeRead(pump, prop, time.Now(), nil)
+	assert.Equal(t, 2, testutil.CollectAndCount(m.metrics.readDuration))
+
+	// The series of the removed device are no longer exported.
+	m.metrics.removeDevice("sensor")
+	assert.Equal(t, 1, testutil.CollectAndCount(m.metrics.readDuration))
+	assert.Equal(t, 0, testutil.CollectAndCount(m.metrics.readErrors))
+	assert.Equal(t, 0, testutil.CollectAndCount(m.metrics.publishes))
+}
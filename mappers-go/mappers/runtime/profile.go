@@ -0,0 +1,362 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+// ProfileError is a problem of the device profile, at the JSON path of its location such
+// as $.deviceInstances[0].propertyVisitors[1].visitorConfig.
+type ProfileError struct {
+	Path    string
+	Message string
+}
+
+// Error return the path and the message of the problem.
+func (e ProfileError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ProfileErrors is the problems of a device profile.
+type ProfileErrors []ProfileError
+
+// Error return all problems, one per line.
+func (e ProfileErrors) Error() string {
+	lines := make([]string, 0, len(e)+1)
+	if len(e) == 1 {
+		lines = append(lines, "1 problem in the device profile:")
+	} else {
+		lines = append(lines, fmt.Sprintf("%d problems in the device profile:", len(e)))
+	}
+	for _, pe := range e {
+		lines = append(lines, "  "+pe.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Validate check the device profile in the configmap file and return all its problems as
+// ProfileErrors. The profile is checked as the devices would start with the driver of
+// the mapper, and the configs of the protocols and the visitors are checked against the
+// schema of the driver if it is a SchemaDriver.
+func (m *Mapper) Validate(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var profile common.DeviceProfile
+	if err = json.Unmarshal(data, &profile); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return ProfileErrors{{Path: fieldPath(typeErr.Field),
+				Message: fmt.Sprintf("must be %s instead of %s", describeType(typeErr.Type), typeErr.Value)}}
+		}
+		return ProfileErrors{{Path: "$", Message: err.Error()}}
+	}
+	if errs := m.validateProfile(profile); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// fieldPath return the JSON path of the field of a json.UnmarshalTypeError, such as
+// $.deviceInstances[0].id for deviceInstances.0.id.
+func fieldPath(field string) string {
+	path := "$"
+	for _, name := range strings.Split(field, ".") {
+		if _, err := strconv.Atoi(name); err == nil {
+			path += "[" + name + "]"
+		} else if name != "" {
+			path += "." + name
+		}
+	}
+	return path
+}
+
+// profileValidator collects the problems of a device profile.
+type profileValidator struct {
+	mapper  *Mapper
+	profile common.DeviceProfile
+	errs    ProfileErrors
+	// protocols and models are the indexes of the protocols and the device models by name.
+	protocols map[string]int
+	models    map[string]int
+}
+
+// validateProfile return the problems of the device profile.
+func (m *Mapper) validateProfile(profile common.DeviceProfile) ProfileErrors {
+	v := &profileValidator{mapper: m, profile: profile,
+		protocols: make(map[string]int),
+		models:    make(map[string]int)}
+	for i := range profile.Protocols {
+		v.checkProtocol(i)
+	}
+	for i := range profile.DeviceModels {
+		v.checkModel(i)
+	}
+	ids := make(map[string]int)
+	for i := range profile.DeviceInstances {
+		path := fmt.Sprintf("$.deviceInstances[%d]", i)
+		id := profile.DeviceInstances[i].ID
+		if id == "" {
+			v.add(path+".id", "device has no id")
+		} else if j, ok := ids[id]; ok {
+			v.add(path+".id", "duplicate device id %q, also at $.deviceInstances[%d]", id, j)
+		} else {
+			ids[id] = i
+		}
+		v.checkInstance(path, profile.DeviceInstances[i])
+	}
+	return v.errs
+}
+
+// add add a problem at the path.
+func (v *profileValidator) add(path string, format string, args ...interface{}) {
+	v.errs = append(v.errs, ProfileError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// checkProtocol check the protocol at the index has a unique name and configs of the schema.
+func (v *profileValidator) checkProtocol(i int) {
+	protocol := v.profile.Protocols[i]
+	path := fmt.Sprintf("$.protocols[%d]", i)
+	if protocol.Name == "" {
+		v.add(path+".name", "protocol has no name")
+	} else if j, ok := v.protocols[protocol.Name]; ok {
+		v.add(path+".name", "duplicate protocol %q, also at $.protocols[%d]", protocol.Name, j)
+	} else {
+		v.protocols[protocol.Name] = i
+	}
+	if schema, ok := v.mapper.Driver.(SchemaDriver); ok {
+		config, commonConfig := schema.ProtocolSchema()
+		v.errs = append(v.errs, checkSchema(path+".protocolConfig", protocol.ProtocolConfigs, typesOf(config), nil)...)
+		v.errs = append(v.errs, checkSchema(path+".protocolCommonConfig", protocol.ProtocolCommonConfig, typesOf(commonConfig), nil)...)
+	}
+}
+
+// checkModel check the device model at the index has a unique name, and its properties
+// unique names, known data types, access modes and ranges.
+func (v *profileValidator) checkModel(i int) {
+	model := v.profile.DeviceModels[i]
+	path := fmt.Sprintf("$.deviceModels[%d]", i)
+	if model.Name == "" {
+		v.add(path+".name", "device model has no name")
+	} else if j, ok := v.models[model.Name]; ok {
+		v.add(path+".name", "duplicate device model %q, also at $.deviceModels[%d]", model.Name, j)
+	} else {
+		v.models[model.Name] = i
+	}
+
+	names := make(map[string]int)
+	for k, property := range model.Properties {
+		propPath := fmt.Sprintf("%s.properties[%d]", path, k)
+		if property.Name == "" {
+			v.add(propPath+".name", "property has no name")
+		} else if l, ok := names[property.Name]; ok {
+			v.add(propPath+".name", "duplicate property %q, also at %s.properties[%d]", property.Name, path, l)
+		} else {
+			names[property.Name] = k
+		}
+		if property.DataType != "" {
+			if err := common.CheckType(property.DataType); err != nil {
+				v.add(propPath+".dataType", "%v", err)
+			}
+		}
+		switch property.AccessMode {
+		case "", common.AccessModeReadWrite, common.AccessModeReadOnly, common.AccessModeWriteOnly:
+		default:
+			v.add(propPath+".accessMode", "access mode must be %s, %s or %s instead of %q",
+				common.AccessModeReadWrite, common.AccessModeReadOnly, common.AccessModeWriteOnly, property.AccessMode)
+		}
+		if property.Minimum > property.Maximum && property.Maximum != 0 {
+			v.add(propPath+".minimum", "minimum %d is greater than maximum %d", property.Minimum, property.Maximum)
+		}
+	}
+}
+
+// property return the property of the device model, and whether the model and the property
+// are found.
+func (v *profileValidator) property(modelName string, name string) (*common.Property, bool, bool) {
+	i, ok := v.models[modelName]
+	if !ok {
+		return nil, false, false
+	}
+	for k := range v.profile.DeviceModels[i].Properties {
+		if v.profile.DeviceModels[i].Properties[k].Name == name {
+			return &v.profile.DeviceModels[i].Properties[k], true, true
+		}
+	}
+	return nil, true, false
+}
+
+// checkInstance check the references and the configs of the device instance at the path,
+// then build its properties and attributes as the device would start.
+func (v *profileValidator) checkInstance(path string, instance common.DeviceInstance) {
+	if i, ok := v.protocols[instance.ProtocolName]; ok {
+		instance.PProtocol = v.profile.Protocols[i]
+	} else {
+		v.add(path+".protocol", "protocol %q not found", instance.ProtocolName)
+	}
+	if _, ok := v.models[instance.Model]; instance.Model != "" && !ok {
+		v.add(path+".model", "device model %q not found", instance.Model)
+	}
+
+	var visitorSchema []reflect.Type
+	if schema, ok := v.mapper.Driver.(SchemaDriver); ok {
+		visitorSchema = typesOf(schema.VisitorSchema())
+	}
+	runtimeSchema := map[string][]reflect.Type{"configData": typesOf(&configData{})}
+
+	visitors := make(map[string]int)
+	linked := make(map[int]bool)
+	for k := range instance.PropertyVisitors {
+		visitor := &instance.PropertyVisitors[k]
+		visitorPath := fmt.Sprintf("%s.propertyVisitors[%d]", path, k)
+		if visitor.PropertyName == "" {
+			v.add(visitorPath+".propertyName", "property visitor has no propertyName")
+		} else if l, ok := visitors[visitor.PropertyName]; ok {
+			v.add(visitorPath+".propertyName", "duplicate visitor of %q, also at %s.propertyVisitors[%d]",
+				visitor.PropertyName, path, l)
+		} else {
+			visitors[visitor.PropertyName] = k
+		}
+		if instance.Model != "" && visitor.ModelName != "" && visitor.ModelName != instance.Model {
+			v.add(visitorPath+".modelName", "device model %q differs from the model %q of the device",
+				visitor.ModelName, instance.Model)
+		}
+		property, modelFound, found := v.property(visitor.ModelName, visitor.PropertyName)
+		switch {
+		case !modelFound:
+			v.add(visitorPath+".modelName", "device model %q not found", visitor.ModelName)
+		case !found:
+			v.add(visitorPath+".propertyName", "property %q not found in device model %q",
+				visitor.PropertyName, visitor.ModelName)
+		default:
+			visitor.PProperty = *property
+			linked[k] = true
+		}
+		if visitor.CollectCycle < 0 {
+			v.add(visitorPath+".collectCycle", "collect cycle must not be negative")
+		}
+		if visitor.ReportCycle < 0 {
+			v.add(visitorPath+".reportCycle", "report cycle must not be negative")
+		}
+		if visitorSchema != nil {
+			v.errs = append(v.errs, checkSchema(visitorPath+".visitorConfig", visitor.VisitorConfig,
+				visitorSchema, runtimeSchema)...)
+		}
+	}
+
+	twins := make(map[string]int)
+	for k, twin := range instance.Twins {
+		twinPath := fmt.Sprintf("%s.twins[%d]", path, k)
+		if l, ok := twins[twin.PropertyName]; ok {
+			v.add(twinPath+".propertyName", "duplicate twin %q, also at %s.twins[%d]", twin.PropertyName, path, l)
+			continue
+		}
+		twins[twin.PropertyName] = k
+		l, ok := visitors[twin.PropertyName]
+		if !ok {
+			v.add(twinPath+".propertyName", "twin %q has no property visitor", twin.PropertyName)
+			continue
+		}
+		dataType := instance.PropertyVisitors[l].PProperty.DataType
+		if metadataType := twin.Desired.Metadatas.Type; linked[l] && !compatibleType(metadataType, dataType) {
+			v.add(twinPath+".desired.metadata.type", "type %q differs from the data type %q of the property",
+				metadataType, dataType)
+		}
+		if dataType != "" && twin.Desired.Value != "" {
+			if _, err := common.Convert(dataType, twin.Desired.Value); err != nil && !errors.Is(err, common.ErrUnknownType) {
+				v.add(twinPath+".desired.value", "%v", err)
+			}
+		}
+	}
+	for k, data := range instance.Datas.Properties {
+		dataPath := fmt.Sprintf("%s.data.dataProperties[%d]", path, k)
+		l, ok := visitors[data.PropertyName]
+		if !ok {
+			v.add(dataPath+".propertyName", "data property %q has no property visitor", data.PropertyName)
+			continue
+		}
+		if _, ok := twins[data.PropertyName]; ok {
+			v.add(dataPath+".propertyName", "%q is a twin too, it is reported to the twin only", data.PropertyName)
+		}
+		dataType := instance.PropertyVisitors[l].PProperty.DataType
+		if metadataType := data.Metadatas.Type; linked[l] && !compatibleType(metadataType, dataType) {
+			v.add(dataPath+".metadata.type", "type %q differs from the data type %q of the property",
+				metadataType, dataType)
+		}
+	}
+
+	// Build the properties and the attributes as the device would start.
+	dev := &Device{Instance: instance, mapper: v.mapper}
+	for k := range instance.PropertyVisitors {
+		if !linked[k] {
+			continue
+		}
+		visitorPath := fmt.Sprintf("%s.propertyVisitors[%d]", path, k)
+		prop, err := dev.newProperty(&dev.Instance.PropertyVisitors[k])
+		if err != nil {
+			v.add(visitorPath+".visitorConfig", "%v", err)
+			continue
+		}
+		if value, ok := prop.DefaultValue(); ok && prop.validator != nil {
+			if err = prop.validator.validate(value); err != nil {
+				v.add(visitorPath, "default value of the device model: %v", err)
+			}
+		}
+	}
+	attributes := make(map[string]int)
+	for k := range instance.Attributes {
+		attrPath := fmt.Sprintf("%s.attributes[%d]", path, k)
+		attr := &dev.Instance.Attributes[k]
+		if l, ok := attributes[attr.Name]; ok && attr.Name != "" {
+			v.add(attrPath+".name", "duplicate attribute %q, also at %s.attributes[%d]", attr.Name, path, l)
+		}
+		attributes[attr.Name] = k
+		if visitorSchema != nil && len(attr.VisitorConfig) > 0 {
+			v.errs = append(v.errs, checkSchema(attrPath+".visitorConfig", attr.VisitorConfig, visitorSchema, nil)...)
+		}
+		if _, err := dev.newAttribute(attr); err != nil {
+			v.add(attrPath, "%v", err)
+		}
+	}
+}
+
+// compatibleType return whether the type of the twin metadata suits the data type of the
+// property. The values are strings on the wire, and edgecore names the types integer,
+// float and boolean.
+func compatibleType(metadataType string, dataType string) bool {
+	switch metadataType {
+	case "", dataType, "string":
+		return true
+	case "integer":
+		return common.IsInteger(dataType)
+	case "float", "double":
+		return common.IsNumeric(dataType)
+	case "boolean":
+		return dataType == "boolean"
+	}
+	return dataType == ""
+}
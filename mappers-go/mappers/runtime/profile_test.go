@@ -0,0 +1,141 @@
+package runtime
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// schemaDriver is a fakeDriver with the schema of its configs.
+type schemaDriver struct {
+	fakeDriver
+}
+
+type testProtocolConfig struct {
+	ConfigData struct {
+		Server string `json:"server"`
+	} `json:"configData"`
+}
+
+type testVisitorConfig struct {
+	ConfigData struct {
+		PathField string `json:"pathField"`
+		Width     int    `json:"width"`
+	} `json:"configData"`
+}
+
+func (d *schemaDriver) ProtocolSchema() (interface{}, interface{}) {
+	return &testProtocolConfig{}, &struct{}{}
+}
+
+func (d *schemaDriver) VisitorSchema() interface{} {
+	return &testVisitorConfig{}
+}
+
+const testProfile = `{
+  "protocols": [
+    {"name": "coap", "protocolConfig": {"configData": {"server": "127.0.0.1:5683", "port": 1}}},
+    {"name": "coap"}
+  ],
+  "deviceModels": [
+    {"name": "sensor", "properties": [
+      {"name": "temperature", "dataType": "int", "maximum": 100},
+      {"name": "mode", "dataType": "enum(auto=0,off=1)"}
+    ]}
+  ],
+  "deviceInstances": [
+    {"id": "sensor-1", "protocol": "coap", "model": "sensor",
+     "twins": [
+       {"propertyName": "temperature", "desired": {"value": "hot", "metadata": {"type": "integer"}}},
+       {"propertyName": "humidity"}
+     ],
+     "propertyVisitors": [
+       {"propertyName": "temperature", "modelName": "sensor",
+        "visitorConfig": {"configData": {"pathField": "temperature", "width": "2", "deviceUnit": "°F"}}},
+       {"propertyName": "mode", "modelName": "sensor", "collectCycle": -1,
+        "visitorConfig": {"configData": {"pathField": "mode", "readExpression": "raw +"}}}
+     ]},
+    {"id": "sensor-1", "protocol": "mqtt", "model": "sensor"}
+  ]
+}`
+
+func TestValidateProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profile")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "deviceProfile.json")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(testProfile), 0644))
+
+	m := NewMapper(&schemaDriver{fakeDriver{values: make(map[string]string)}}, nil)
+	err = m.Validate(path)
+	errs, ok := err.(ProfileErrors)
+	assert.True(t, ok)
+	paths := make([]string, 0, len(errs))
+	for _, pe := range errs {
+		paths = append(paths, pe.Path)
+	}
+	assert.Equal(t, []string{
+		"$.protocols[0].protocolConfig.configData.port",
+		"$.protocols[1].name",
+		"$.deviceInstances[0].propertyVisitors[0].visitorConfig.configData.width",
+		"$.deviceInstances[0].propertyVisitors[1].collectCycle",
+		"$.deviceInstances[0].twins[0].desired.value",
+		"$.deviceInstances[0].twins[1].propertyName",
+		"$.deviceInstances[0].propertyVisitors[0].visitorConfig",
+		"$.deviceInstances[0].propertyVisitors[1].visitorConfig",
+		"$.deviceInstances[1].id",
+		"$.deviceInstances[1].protocol",
+	}, paths)
+	assert.Equal(t, "must be an integer instead of string", errs[2].Message)
+	assert.Contains(t, err.Error(), "10 problems in the device profile:\n  $.protocols[0]")
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"deviceInstances": [{"id": 1}]}`), 0644))
+	errs, ok = m.Validate(path).(ProfileErrors)
+	assert.True(t, ok)
+	assert.Equal(t, 1, len(errs))
+	// Older Go versions don't report the index of the array.
+	assert.Regexp(t, `^\$\.deviceInstances(\[0\])?\.id$`, errs[0].Path)
+	assert.Equal(t, "must be a string instead of number", errs[0].Message)
+}
+
+const partialProfile = `{
+  "protocols": [{"name": "coap"}],
+  "deviceModels": [{"name": "sensor", "properties": [{"name": "temperature", "dataType": "int"}]}],
+  "deviceInstances": [
+    {"id": "good", "protocol": "coap", "model": "sensor",
+     "propertyVisitors": [{"propertyName": "temperature", "modelName": "sensor"}]},
+    {"id": "no-protocol", "protocol": "mqtt", "model": "sensor"},
+    {"id": "no-visitor", "protocol": "coap", "model": "sensor",
+     "twins": [{"propertyName": "temperature"}]}
+  ]
+}`
+
+func TestInitPartial(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profile")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "deviceProfile.json")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(partialProfile), 0644))
+
+	m := NewMapper(&fakeDriver{values: make(map[string]string)}, nil)
+	assert.Nil(t, m.Init(path))
+	assert.Equal(t, 3, len(m.Devices()))
+	good, _ := m.Device("good")
+	assert.Nil(t, good.Err())
+	assert.Equal(t, "temperature", good.Instance.PropertyVisitors[0].PProperty.Name)
+	noProtocol, _ := m.Device("no-protocol")
+	assert.EqualError(t, noProtocol.Err(), `protocol "mqtt" not found`)
+	noVisitor, _ := m.Device("no-visitor")
+	assert.EqualError(t, noVisitor.Err(), `twin "temperature" has no property visitor`)
+	assert.Equal(t, "ERROR", noVisitor.info(false).Status)
+
+	strict := NewMapper(&fakeDriver{values: make(map[string]string)}, nil)
+	strict.Strict = true
+	errs, ok := strict.Init(path).(ProfileErrors)
+	assert.True(t, ok)
+	assert.Equal(t, 2, len(errs))
+	assert.Equal(t, 0, len(strict.Devices()))
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/klog/v2"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+// configmapDataDir is the symlink Kubernetes swaps to update the files of a mounted configmap.
+const configmapDataDir = "..data"
+
+// watch reload the configmap when it changes. The directory is watched instead of the
+// file, because Kubernetes updates a mounted configmap by swapping the ..data symlink.
+func (m *Mapper) watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	if err = watcher.Add(filepath.Dir(m.path)); err != nil {
+		return err
+	}
+	klog.V(1).Info("Watch configmap: ", m.path)
+
+	name := filepath.Base(m.path)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			base := filepath.Base(event.Name)
+			if base != name && base != configmapDataDir {
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
+				continue
+			}
+			klog.V(2).Infof("Configmap event: %v", event)
+			if err := m.reload(); err != nil {
+				klog.Errorf("Reload configmap failed, the devices keep running: %v", err)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			klog.Errorf("Watch configmap error: %v", err)
+		}
+	}
+}
+
+// reload parse the configmap again. Removed devices are stopped, new devices are
+// started and changed devices are restarted, unchanged devices keep running.
+func (m *Mapper) reload() error {
+	profile, err := ioutil.ReadFile(m.path)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	unchanged := bytes.Equal(profile, m.profile)
+	m.mu.Unlock()
+	if unchanged {
+		return nil
+	}
+
+	if m.Strict {
+		if err = m.Validate(m.path); err != nil {
+			return err
+		}
+	}
+
+	devices := make(map[string]*Device)
+	models := make(map[string]common.DeviceModel)
+	protocols := make(map[string]common.Protocol)
+	if err = Parse(m.path, devices, models, protocols); err != nil {
+		return err
+	}
+	for _, dev := range devices {
+		dev.mapper = m
+		dev.profile = instanceProfile(dev.Instance)
+	}
+
+	m.mu.Lock()
+	m.profile = profile
+	m.models = models
+	m.protocols = protocols
+	stopped, started := diffDevices(m.devices, devices)
+	for _, dev := range stopped {
+		delete(m.devices, dev.Instance.ID)
+	}
+	for _, dev := range started {
+		m.devices[dev.Instance.ID] = dev
+	}
+	m.mu.Unlock()
+
+	klog.Infof("Reload configmap: %d devices stopped, %d devices started", len(stopped), len(started))
+	ctx := m.context()
+	for _, dev := range stopped {
+		stopCtx, cancel := context.WithTimeout(context.Background(), m.ShutdownTimeout)
+		dev.stop(stopCtx, "")
+		cancel()
+		if _, ok := m.Device(dev.Instance.ID); !ok {
+			m.forgetDevice(dev.Instance.ID)
+		}
+	}
+	for _, dev := range started {
+		dev.start(ctx)
+	}
+	return nil
+}
+
+// diffDevices compare the running devices with the devices of the new configmap. It returns
+// the devices to stop, which are removed or changed, and the devices to start, which are new
+// or changed. The devices which failed are started again. Dynamic devices are kept unless
+// the configmap has a device of the same ID.
+func diffDevices(running map[string]*Device, devices map[string]*Device) (stopped []*Device, started []*Device) {
+	for id, dev := range running {
+		if _, ok := devices[id]; !ok && !dev.Dynamic {
+			stopped = append(stopped, dev)
+		}
+	}
+	for id, dev := range devices {
+		if old, ok := running[id]; ok {
+			if !old.Dynamic && old.Err() == nil && bytes.Equal(old.profile, dev.profile) {
+				continue
+			}
+			stopped = append(stopped, old)
+		}
+		started = append(started, dev)
+	}
+	return stopped, started
+}
@@ -0,0 +1,25 @@
+package runtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffDevices(t *testing.T) {
+	running := map[string]*Device{
+		"unchanged": {profile: []byte(`{"id":"unchanged"}`)},
+		"changed":   {profile: []byte(`{"id":"changed"}`)},
+		"removed":   {profile: []byte(`{"id":"removed"}`)},
+		"onboarded": {profile: []byte(`{"id":"onboarded"}`), Dynamic: true},
+	}
+	devices := map[string]*Device{
+		"unchanged": {profile: []byte(`{"id":"unchanged"}`)},
+		"changed":   {profile: []byte(`{"id":"changed","protocol":"new"}`)},
+		"added":     {profile: []byte(`{"id":"added"}`)},
+	}
+
+	stopped, started := diffDevices(running, devices)
+	assert.ElementsMatch(t, []*Device{running["changed"], running["removed"]}, stopped)
+	assert.ElementsMatch(t, []*Device{devices["changed"], devices["added"]}, started)
+}
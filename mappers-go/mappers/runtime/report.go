@@ -0,0 +1,312 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+// sample is a value collected from the device, with its timestamp in milliseconds.
+type sample struct {
+	Value     string `json:"value"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// aggregator buffers the samples of a property between its reports.
+type aggregator struct {
+	aggregation string
+	cycle       time.Duration
+
+	mu      sync.Mutex
+	samples []sample
+}
+
+// add buffer a sample until the next report.
+func (a *aggregator) add(value string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.samples = append(a.samples, sample{Value: value, Timestamp: time.Now().UnixNano() / 1e6})
+}
+
+// take return the buffered samples and clear them.
+func (a *aggregator) take() []sample {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	samples := a.samples
+	a.samples = nil
+	return samples
+}
+
+// configData is the runtime configs in the configData of the visitor config.
+type configData struct {
+	common.ReportConfig
+	common.ValidateConfig
+	common.UnitConfig
+	common.ExpressionConfig
+}
+
+// parseVisitorConfig parse the report, validate, unit and expression configs in the
+// configData of the visitor config.
+func parseVisitorConfig(visitor *common.PropertyVisitor) (configData, error) {
+	var config struct {
+		ConfigData configData `json:"configData"`
+	}
+	if len(visitor.VisitorConfig) != 0 {
+		if err := json.Unmarshal(visitor.VisitorConfig, &config); err != nil {
+			return configData{}, fmt.Errorf("unmarshal visitor config error: %v", err)
+		}
+	}
+	report := config.ConfigData.ReportConfig
+	if report.Deadband < 0 || report.DeadbandPercent < 0 || report.MaxSilence < 0 {
+		return config.ConfigData, fmt.Errorf("deadband, deadbandPercent and maxSilence must not be negative")
+	}
+	return config.ConfigData, nil
+}
+
+// filtered return whether the report config filters the values which didn't change.
+func filtered(config common.ReportConfig) bool {
+	return config.OnChange || config.Deadband > 0 || config.DeadbandPercent > 0
+}
+
+// exceeds return whether the value differs from the last reported value by more than
+// the deadbands. Values which aren't numbers, or without deadbands, are compared as is.
+func exceeds(config common.ReportConfig, last string, value string) bool {
+	if config.Deadband == 0 && config.DeadbandPercent == 0 {
+		return value != last
+	}
+	l, err := strconv.ParseFloat(last, 64)
+	if err != nil {
+		return value != last
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value != last
+	}
+
+	diff := math.Abs(v - l)
+	if config.Deadband > 0 && diff > config.Deadband {
+		return true
+	}
+	if config.DeadbandPercent > 0 {
+		if l == 0 {
+			return diff != 0
+		}
+		return diff*100/math.Abs(l) > config.DeadbandPercent
+	}
+	return false
+}
+
+// changed return whether the value is reported under the report config of the property.
+// The first value is always reported, and so is a value after MaxSilence without report.
+func (p *Property) changed(value string) bool {
+	if !filtered(p.Report) {
+		return true
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.reportedAt.IsZero() {
+		return true
+	}
+	if p.Report.MaxSilence > 0 && time.Since(p.reportedAt) >= time.Duration(p.Report.MaxSilence)*time.Millisecond {
+		return true
+	}
+	return exceeds(p.Report, p.reported, value)
+}
+
+// setReported record the value reported for the property.
+func (p *Property) setReported(value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.reported = value
+	p.reportedAt = time.Now()
+}
+
+// publishChanged publish a value of the property, unless the report config filters it
+// because it didn't change. If the batch isn't nil, the value is added to it instead.
+func (d *Device) publishChanged(b *batch, reportTo string, prop *Property, dataType string, value string) error {
+	if !prop.changed(value) {
+		klog.V(4).Infof("The %s value %s of %s is unchanged, not reported", prop.Name, value, d.Instance.ID)
+		d.mapper.metrics.observeUnchanged(d, prop)
+		return nil
+	}
+	if b != nil {
+		b.add(reportTo, prop, dataType, value)
+		return nil
+	}
+	if err := d.publish(reportTo, prop, dataType, value); err != nil {
+		return err
+	}
+	prop.setReported(value)
+	return nil
+}
+
+// newAggregator return the aggregator of a property with a report cycle, nil if the
+// property has no report cycle and every sample is reported at once.
+func newAggregator(visitor *common.PropertyVisitor, config common.ReportConfig) (*aggregator, error) {
+	if visitor.ReportCycle <= 0 {
+		if config.Aggregation != "" {
+			return nil, fmt.Errorf("aggregation %s needs a reportCycle", config.Aggregation)
+		}
+		return nil, nil
+	}
+	switch config.Aggregation {
+	case "":
+		config.Aggregation = common.AggregationLast
+	case common.AggregationLast, common.AggregationMin, common.AggregationMax, common.AggregationMean,
+		common.AggregationSum, common.AggregationCount, common.AggregationAll:
+	default:
+		return nil, fmt.Errorf("unknown aggregation: %s", config.Aggregation)
+	}
+	return &aggregator{aggregation: config.Aggregation,
+		cycle: time.Duration(visitor.ReportCycle) * time.Millisecond}, nil
+}
+
+// aggregate return the value reported for the samples with its data type. The mean of
+// an int property is rounded, so the reported value keeps the type of the property.
+func aggregate(aggregation string, dataType string, samples []sample) (string, string, error) {
+	switch aggregation {
+	case common.AggregationLast:
+		return samples[len(samples)-1].Value, dataType, nil
+	case common.AggregationCount:
+		return strconv.Itoa(len(samples)), "int", nil
+	}
+
+	values := make([]float64, 0, len(samples))
+	for _, s := range samples {
+		v, err := strconv.ParseFloat(s.Value, 64)
+		if err != nil {
+			return "", "", fmt.Errorf("%s of %s values: %s is not a number", aggregation, dataType, s.Value)
+		}
+		values = append(values, v)
+	}
+	result := values[0]
+	for _, v := range values[1:] {
+		switch aggregation {
+		case common.AggregationMin:
+			result = math.Min(result, v)
+		case common.AggregationMax:
+			result = math.Max(result, v)
+		case common.AggregationMean, common.AggregationSum:
+			result += v
+		}
+	}
+	if aggregation == common.AggregationMean {
+		result /= float64(len(values))
+		if dataType == "int" {
+			result = math.Round(result)
+		}
+	}
+	return strconv.FormatFloat(result, 'f', -1, 64), dataType, nil
+}
+
+// collect report a value of the property, or buffer it until the next report if the
+// property has a report cycle. The value is added to the batch if it isn't nil.
+func (d *Device) collect(b *batch, reportTo string, prop *Property, value string) error {
+	if prop.aggregator != nil {
+		prop.aggregator.add(value)
+		return nil
+	}
+	return d.publishChanged(b, reportTo, prop, prop.DataType, value)
+}
+
+// initReport start a timer for each report cycle of the properties, which reports their
+// samples together.
+func (d *Device) initReport() {
+	reports := make(map[time.Duration][]*Property)
+	for i := 0; i < len(d.Instance.PropertyVisitors); i++ {
+		prop, ok := d.Properties[d.Instance.PropertyVisitors[i].PropertyName]
+		if !ok || prop.aggregator == nil {
+			continue
+		}
+		reports[prop.aggregator.cycle] = append(reports[prop.aggregator.cycle], prop)
+	}
+	for cycle, props := range reports {
+		props := props
+		d.startTimer(fmt.Sprintf("report %v", cycle), func() { d.flush(props) }, cycle)
+	}
+}
+
+// flushAll report the samples of all properties collected since their last report.
+func (d *Device) flushAll() {
+	var props []*Property
+	for _, prop := range d.Properties {
+		if prop.aggregator != nil {
+			props = append(props, prop)
+		}
+	}
+	d.flush(props)
+}
+
+// flush report the samples of the properties collected since their last report, in one
+// twin update and one data message.
+func (d *Device) flush(props []*Property) {
+	b := &batch{}
+	for _, prop := range props {
+		d.flushProperty(b, prop)
+	}
+	d.publishBatch(b)
+}
+
+// flushProperty add the aggregated samples of the property to the batch. With the all
+// aggregation, the samples go to data only, with the last one reported to the twin of a
+// twin property.
+func (d *Device) flushProperty(b *batch, prop *Property) {
+	samples := prop.aggregator.take()
+	if len(samples) == 0 || prop.ReportTo == "" {
+		return
+	}
+
+	if prop.aggregator.aggregation == common.AggregationAll {
+		all, err := json.Marshal(samples)
+		if err != nil {
+			klog.Errorf("Marshal samples of %s failed: %v", prop.Name, err)
+			return
+		}
+		last := samples[len(samples)-1].Value
+		b.addSamples(prop, string(all), last)
+		if prop.ReportTo == ReportToTwin {
+			if err = d.publishChanged(b, ReportToTwin, prop, prop.DataType, last); err != nil {
+				klog.Error(err)
+			}
+		}
+		return
+	}
+
+	value, dataType, err := aggregate(prop.aggregator.aggregation, prop.DataType, samples)
+	if err != nil {
+		klog.Errorf("Aggregate %s of %s failed: %v", prop.Name, d.Instance.ID, err)
+		return
+	}
+	if err = d.publishChanged(b, prop.ReportTo, prop, dataType, value); err != nil {
+		klog.Error(err)
+		return
+	}
+	klog.V(1).Infof("Report the %s of %d %s values as %s", prop.aggregator.aggregation, len(samples), prop.Name, value)
+}
@@ -0,0 +1,189 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+func TestNewAggregator(t *testing.T) {
+	visitor := &common.PropertyVisitor{VisitorConfig: json.RawMessage(`{"configData":{"aggregation":"mean"}}`)}
+	config, err := parseVisitorConfig(visitor)
+	assert.Nil(t, err)
+	assert.Equal(t, common.AggregationMean, config.Aggregation)
+
+	_, err = newAggregator(visitor, config.ReportConfig)
+	assert.NotNil(t, err)
+
+	visitor.ReportCycle = 10000
+	a, err := newAggregator(visitor, config.ReportConfig)
+	assert.Nil(t, err)
+	assert.Equal(t, common.AggregationMean, a.aggregation)
+	assert.Equal(t, 10*time.Second, a.cycle)
+
+	a, err = newAggregator(visitor, common.ReportConfig{})
+	assert.Nil(t, err)
+	assert.Equal(t, common.AggregationLast, a.aggregation)
+
+	_, err = newAggregator(visitor, common.ReportConfig{Aggregation: "median"})
+	assert.NotNil(t, err)
+
+	a, err = newAggregator(&common.PropertyVisitor{}, common.ReportConfig{})
+	assert.Nil(t, err)
+	assert.Nil(t, a)
+}
+
+func TestAggregate(t *testing.T) {
+	samples := []sample{{Value: "3"}, {Value: "8"}, {Value: "2"}}
+	tests := []struct {
+		aggregation string
+		dataType    string
+		value       string
+		valueType   string
+	}{
+		{common.AggregationLast, "int", "2", "int"},
+		{common.AggregationMin, "int", "2", "int"},
+		{common.AggregationMax, "int", "8", "int"},
+		{common.AggregationSum, "int", "13", "int"},
+		{common.AggregationMean, "int", "4", "int"},
+		{common.AggregationMean, "double", "4.333333333333333", "double"},
+		{common.AggregationCount, "double", "3", "int"},
+	}
+	for _, test := range tests {
+		value, valueType, err := aggregate(test.aggregation, test.dataType, samples)
+		assert.Nil(t, err, test.aggregation)
+		assert.Equal(t, test.value, value, test.aggregation)
+		assert.Equal(t, test.valueType, valueType, test.aggregation)
+	}
+
+	_, _, err := aggregate(common.AggregationMax, "string", []sample{{Value: "on"}})
+	assert.NotNil(t, err)
+}
+
+func TestAggregatorTake(t *testing.T) {
+	a := &aggregator{aggregation: common.AggregationAll}
+	a.add("1")
+	a.add("2")
+	samples := a.take()
+	assert.Equal(t, 2, len(samples))
+	assert.Equal(t, "2", samples[1].Value)
+	assert.Empty(t, a.take())
+}
+
+func TestPropertyChanged(t *testing.T) {
+	prop := &Property{}
+	assert.True(t, prop.changed("20"))
+	prop.setReported("20")
+	assert.True(t, prop.changed("20"))
+
+	prop.Report = common.ReportConfig{OnChange: true}
+	assert.False(t, prop.changed("20"))
+	assert.True(t, prop.changed("21"))
+
+	prop.Report = common.ReportConfig{Deadband: 0.5}
+	assert.False(t, prop.changed("20.5"))
+	assert.True(t, prop.changed("19.4"))
+
+	prop.Report = common.ReportConfig{DeadbandPercent: 10}
+	assert.False(t, prop.changed("22"))
+	assert.True(t, prop.changed("22.5"))
+
+	prop.Report = common.ReportConfig{OnChange: true, MaxSilence: 1000}
+	assert.False(t, prop.changed("20"))
+	prop.reportedAt = time.Now().Add(-2 * time.Second)
+	assert.True(t, prop.changed("20"))
+
+	prop.Report = common.ReportConfig{Deadband: 1}
+	prop.setReported("on")
+	assert.False(t, prop.changed("on"))
+	assert.True(t, prop.changed("off"))
+}
+
+func TestInitCollect(t *testing.T) {
+	driver := &fakeDriver{values: map[string]string{"temperature": "20", "humidity": "40", "pressure": "1000"}}
+	m := NewMapper(driver, nil)
+	instance := common.DeviceInstance{ID: "sensor",
+		Twins: []common.Twin{{PropertyName: "temperature"}, {PropertyName: "humidity"}},
+		Datas: common.Data{Properties: []common.DataProperty{{PropertyName: "pressure"}}},
+		PropertyVisitors: []common.PropertyVisitor{
+			{PropertyName: "temperature", CollectCycle: 1000, PProperty: common.Property{DataType: "int"}},
+			{PropertyName: "humidity", CollectCycle: 1000, PProperty: common.Property{DataType: "int"}},
+			{PropertyName: "pressure", CollectCycle: 2000, PProperty: common.Property{DataType: "int"}}}}
+	dev := &Device{Instance: instance, mapper: m}
+	assert.Nil(t, dev.initProperties())
+
+	dev.ctx, dev.cancel = context.WithCancel(context.Background())
+	dev.cancel()
+	dev.initCollect()
+	dev.wg.Wait()
+
+	names := make([]string, 0)
+	for _, timer := range dev.timerStates() {
+		names = append(names, timer.name)
+	}
+	assert.ElementsMatch(t, []string{"collect 1s", "collect 2s"}, names)
+}
+
+func TestCollections(t *testing.T) {
+	m := NewMapper(&fakeDriver{values: make(map[string]string)}, nil)
+	instance := common.DeviceInstance{ID: "sensor",
+		Twins: []common.Twin{{PropertyName: "temperature"}},
+		Datas: common.Data{Properties: []common.DataProperty{{PropertyName: "temperature"}, {PropertyName: "pressure"}}},
+		PropertyVisitors: []common.PropertyVisitor{
+			{PropertyName: "temperature", CollectCycle: 1000, PProperty: common.Property{DataType: "int"}},
+			{PropertyName: "pressure", CollectCycle: 1000, PProperty: common.Property{DataType: "int"}}}}
+	dev := &Device{Instance: instance, mapper: m}
+	assert.Nil(t, dev.initProperties())
+
+	// The twin which is a data property too is collected once, for the twin.
+	collections := dev.collections()
+	assert.Equal(t, 1, len(collections))
+	assert.Equal(t, []CollectItem{{Property: dev.Properties["temperature"], ReportTo: ReportToTwin},
+		{Property: dev.Properties["pressure"], ReportTo: ReportToData}}, collections[0].Items)
+}
+
+func TestBatchAdd(t *testing.T) {
+	temperature := &Property{Name: "temperature"}
+	pressure := &Property{Name: "pressure"}
+	b := &batch{}
+	b.add(ReportToTwin, temperature, "int", "20")
+	b.add(ReportToData, pressure, "float", "1013.2")
+	assert.Equal(t, []common.PropertyValue{{Name: "temperature", Type: "int", Value: "20"}}, b.twins)
+	assert.Equal(t, []*Property{temperature}, b.twinProps)
+	assert.Equal(t, []common.PropertyValue{{Name: "pressure", Type: "float", Value: "1013.2"}}, b.datas)
+	assert.Equal(t, []*Property{pressure}, b.dataProps)
+}
+
+func TestFlushPropertyAll(t *testing.T) {
+	dev := &Device{Instance: common.DeviceInstance{ID: "sensor"}, mapper: NewMapper(&fakeDriver{}, nil)}
+	newProp := func(name string, reportTo string) *Property {
+		prop := &Property{Name: name, DataType: "int", ReportTo: reportTo,
+			aggregator: &aggregator{aggregation: common.AggregationAll}}
+		prop.aggregator.add("1")
+		prop.aggregator.add("2")
+		return prop
+	}
+
+	// The samples of a twin property go to data, only the last one goes to the twin.
+	b := &batch{}
+	temperature := newProp("temperature", ReportToTwin)
+	dev.flushProperty(b, temperature)
+	assert.Equal(t, []common.PropertyValue{{Name: "temperature", Type: "int", Value: "2"}}, b.twins)
+	assert.Equal(t, []*Property{temperature}, b.twinProps)
+	assert.Equal(t, 1, len(b.datas))
+	assert.Contains(t, b.datas[0].Value, `"value":"1"`)
+	assert.Equal(t, []*Property{nil}, b.dataProps)
+
+	// The last sample of a data property is recorded as reported, not the array.
+	b = &batch{}
+	pressure := newProp("pressure", ReportToData)
+	dev.flushProperty(b, pressure)
+	assert.Empty(t, b.twins)
+	assert.Equal(t, []*Property{pressure}, b.dataProps)
+	assert.Equal(t, map[*Property]string{pressure: "2"}, b.reported)
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// rawMessageType is not checked further, it is decoded by whoever uses it.
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+// checkSchema check the JSON object at the path against the struct types it decodes into.
+// Every field must be a field of one of the types, and must decode into its type. The
+// nested objects are checked the same way, with the extra types of their key allowed too.
+func checkSchema(path string, raw json.RawMessage, types []reflect.Type, extra map[string][]reflect.Type) []ProfileError {
+	if len(bytes.TrimSpace(raw)) == 0 || string(bytes.TrimSpace(raw)) == "null" {
+		return nil
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return []ProfileError{{Path: path, Message: "must be a JSON object"}}
+	}
+
+	fields := make(map[string]reflect.Type)
+	for _, t := range types {
+		jsonFields(t, fields)
+	}
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []ProfileError
+	for _, key := range keys {
+		fieldPath := path + "." + key
+		var nested []reflect.Type
+		if t, ok := fields[key]; ok {
+			if isObject(t) {
+				nested = append(nested, indirect(t))
+			} else if err := checkValue(object[key], t); err != nil {
+				errs = append(errs, ProfileError{Path: fieldPath, Message: err.Error()})
+				continue
+			}
+		}
+		nested = append(nested, extra[key]...)
+		if len(nested) == 0 {
+			if _, ok := fields[key]; !ok {
+				errs = append(errs, ProfileError{Path: fieldPath, Message: "unknown field"})
+			}
+			continue
+		}
+		errs = append(errs, checkSchema(fieldPath, object[key], nested, nil)...)
+	}
+	return errs
+}
+
+// jsonFields add the JSON fields of the struct type to the fields by name. The fields of
+// the embedded structs without JSON name are added as fields of the struct.
+func jsonFields(t reflect.Type, fields map[string]reflect.Type) {
+	t = indirect(t)
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if f.Anonymous && name == "" && indirect(f.Type).Kind() == reflect.Struct {
+			jsonFields(f.Type, fields)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+}
+
+// indirect return the type a pointer type points to.
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// isObject return whether the type is a struct checked field by field.
+func isObject(t reflect.Type) bool {
+	t = indirect(t)
+	return t.Kind() == reflect.Struct && t != rawMessageType && !t.Implements(reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()) &&
+		!reflect.PtrTo(t).Implements(reflect.TypeOf((*json.Unmarshaler)(nil)).Elem())
+}
+
+// checkValue check the JSON value decodes into the type.
+func checkValue(raw json.RawMessage, t reflect.Type) error {
+	err := json.Unmarshal(raw, reflect.New(t).Interface())
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return fmt.Errorf("must be %s instead of %s", describeType(t), typeErr.Value)
+	}
+	return err
+}
+
+// describeType describe the JSON values of the type in the errors.
+func describeType(t reflect.Type) string {
+	switch indirect(t).Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	}
+	return t.String()
+}
+
+// typesOf return the types of the values.
+func typesOf(values ...interface{}) []reflect.Type {
+	var types []reflect.Type
+	for _, v := range values {
+		if v != nil {
+			types = append(types, reflect.TypeOf(v))
+		}
+	}
+	return types
+}
@@ -0,0 +1,268 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+// apiPrefix is the path prefix of the device API.
+const apiPrefix = "/api/v1/devices"
+
+// DeviceInfo is a device returned by the device API. Error is why the device is in
+// the ERROR status.
+type DeviceInfo struct {
+	ID         string         `json:"id"`
+	Name       string         `json:"name,omitempty"`
+	Model      string         `json:"model,omitempty"`
+	Protocol   string         `json:"protocol,omitempty"`
+	Status     string         `json:"status"`
+	Error      string         `json:"error,omitempty"`
+	Properties []PropertyInfo `json:"properties,omitempty"`
+}
+
+// PropertyInfo is a property returned by the device API. Value is the last value read
+// from the device at Timestamp, in milliseconds.
+type PropertyInfo struct {
+	Name       string `json:"name"`
+	DataType   string `json:"dataType"`
+	AccessMode string `json:"accessMode,omitempty"`
+	ReportTo   string `json:"reportTo,omitempty"`
+	Desired    string `json:"desired,omitempty"`
+	Value      string `json:"value,omitempty"`
+	Timestamp  int64  `json:"timestamp,omitempty"`
+}
+
+// PropertyValue is the body of a property write.
+type PropertyValue struct {
+	Value string `json:"value"`
+}
+
+// apiError is the body of an error response.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// handler return the handler of the embedded HTTP server.
+func (m *Mapper) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, m.metrics.handler())
+	mux.Handle(livenessPath, healthHandler("Liveness", m.live))
+	mux.Handle(readinessPath, healthHandler("Readiness", m.ready))
+	if m.HTTPToken != "" {
+		mux.Handle(apiPrefix, m.authorize(http.HandlerFunc(m.handleDevices)))
+		mux.Handle(apiPrefix+"/", m.authorize(http.HandlerFunc(m.handleDevice)))
+	} else {
+		klog.Warning("No HTTP token is set, the device API is disabled")
+	}
+	return mux
+}
+
+// serve run the embedded HTTP server until the context is cancelled.
+func (m *Mapper) serve(ctx context.Context) {
+	server := &http.Server{Addr: m.HTTPAddress, Handler: m.handler()}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			klog.Errorf("Shutdown HTTP server error: %v", err)
+		}
+	}()
+
+	klog.V(1).Info("HTTP server listens on ", m.HTTPAddress)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		klog.Errorf("HTTP server error: %v", err)
+	}
+}
+
+// authorize check the bearer token of the request.
+func (m *Mapper) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(header, "Bearer ")), []byte(m.HTTPToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "invalid bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleDevices list the devices.
+func (m *Mapper) handleDevices(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	devices := m.Devices()
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Instance.ID < devices[j].Instance.ID })
+	infos := make([]DeviceInfo, 0, len(devices))
+	for _, dev := range devices {
+		infos = append(infos, dev.info(false))
+	}
+	writeJSON(w, http.StatusOK, infos)
+}
+
+// handleDevice serve /{id} with the properties and status of the device, and
+// /{id}/properties/{name} to read or write a property through the driver.
+func (m *Mapper) handleDevice(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, apiPrefix+"/"), "/")
+	dev, ok := m.Device(parts[0])
+	if !ok {
+		writeError(w, http.StatusNotFound, "device not found")
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		writeJSON(w, http.StatusOK, dev.info(true))
+	case len(parts) == 3 && parts[1] == "properties":
+		if err := dev.Err(); err != nil {
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		if !dev.Started() {
+			writeError(w, http.StatusServiceUnavailable, "device is not started")
+			return
+		}
+		prop, ok := dev.properties()[parts[2]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "property not found")
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			dev.handleRead(w, prop)
+		case http.MethodPut:
+			dev.handleWrite(w, r, prop)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// handleRead read the property live from the device.
+func (d *Device) handleRead(w http.ResponseWriter, prop *Property) {
+	if _, err := d.Read(prop); err != nil {
+		if err == ErrWriteOnly {
+			writeError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, d.propertyInfo(prop))
+}
+
+// handleWrite write the property to the device.
+func (d *Device) handleWrite(w http.ResponseWriter, r *http.Request, prop *Property) {
+	var value PropertyValue
+	if err := json.NewDecoder(r.Body).Decode(&value); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body: "+err.Error())
+		return
+	}
+	if err := d.Write(prop, value.Value); err != nil {
+		if err == ErrReadOnly {
+			writeError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		if errors.Is(err, ErrInvalidValue) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	klog.V(1).Infof("Write %s of %s as %s by HTTP API", prop.Name, d.Instance.ID, value.Value)
+	writeJSON(w, http.StatusOK, value)
+}
+
+// info return the device info, with the properties if withProperties is set.
+func (d *Device) info(withProperties bool) DeviceInfo {
+	info := DeviceInfo{ID: d.Instance.ID,
+		Name:     d.Instance.Name,
+		Model:    d.Instance.Model,
+		Protocol: d.Instance.ProtocolName,
+		Status:   d.mapper.Driver.GetStatus(d)}
+	if err := d.Err(); err != nil {
+		info.Status = common.DEVSTERR
+		info.Error = err.Error()
+	}
+	if !withProperties {
+		return info
+	}
+	properties := d.properties()
+	for i := 0; i < len(d.Instance.PropertyVisitors); i++ {
+		if prop, ok := properties[d.Instance.PropertyVisitors[i].PropertyName]; ok {
+			info.Properties = append(info.Properties, d.propertyInfo(prop))
+		}
+	}
+	return info
+}
+
+// propertyInfo return the property info with its last value.
+func (d *Device) propertyInfo(prop *Property) PropertyInfo {
+	info := PropertyInfo{Name: prop.Name,
+		DataType:   prop.DataType,
+		AccessMode: prop.AccessMode(),
+		ReportTo:   prop.ReportTo}
+	for i := 0; i < len(d.Instance.Twins); i++ {
+		if d.Instance.Twins[i].PropertyName == prop.Name {
+			info.Desired = d.desiredValue(&d.Instance.Twins[i])
+			break
+		}
+	}
+	value, updated := prop.Value()
+	if !updated.IsZero() {
+		info.Value = value
+		info.Timestamp = updated.UnixNano() / 1e6
+	}
+	return info
+}
+
+// writeJSON write the response as JSON.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		klog.Errorf("Write HTTP response failed: %v", err)
+	}
+}
+
+// writeError write an error response.
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, apiError{Error: message})
+}
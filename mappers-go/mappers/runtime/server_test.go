@@ -0,0 +1,143 @@
+package runtime
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+func newTestServer(t *testing.T) (*httptest.Server, *fakeDriver) {
+	m, driver := newTestMapper(t)
+	return httptest.NewServer(m.handler()), driver
+}
+
+func newTestMapper(t *testing.T) (*Mapper, *fakeDriver) {
+	driver := &fakeDriver{values: map[string]string{"temperature": "21"}}
+	m := NewMapper(driver, nil)
+	m.HTTPToken = "secret"
+	instance := common.DeviceInstance{ID: "sensor",
+		PropertyVisitors: []common.PropertyVisitor{
+			{PropertyName: "temperature", PProperty: common.Property{DataType: "int", AccessMode: common.AccessModeReadOnly}},
+			{PropertyName: "switch", PProperty: common.Property{DataType: "string", AccessMode: common.AccessModeReadWrite}}}}
+	dev := &Device{Instance: instance, mapper: m}
+	assert.Nil(t, dev.initProperties())
+	dev.setStarted(true)
+	m.devices[dev.Instance.ID] = dev
+	return m, driver
+}
+
+func request(t *testing.T, method string, url string, token string, body string) (int, map[string]interface{}) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	assert.Nil(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	var result map[string]interface{}
+	_ = json.NewDecoder(resp.Body).Decode(&result)
+	return resp.StatusCode, result
+}
+
+func TestServerAuthorize(t *testing.T) {
+	server, _ := newTestServer(t)
+	defer server.Close()
+
+	code, _ := request(t, http.MethodGet, server.URL+apiPrefix, "", "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = request(t, http.MethodGet, server.URL+apiPrefix, "wrong", "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// The token is refused without the Bearer scheme.
+	req, err := http.NewRequest(http.MethodGet, server.URL+apiPrefix, nil)
+	assert.Nil(t, err)
+	req.Header.Set("Authorization", "secret")
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	code, _ = request(t, http.MethodGet, server.URL+apiPrefix+"/sensor", "secret", "")
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestServerReadWrite(t *testing.T) {
+	server, driver := newTestServer(t)
+	defer server.Close()
+
+	code, result := request(t, http.MethodGet, server.URL+apiPrefix+"/sensor/properties/temperature", "secret", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "21", result["value"])
+
+	code, _ = request(t, http.MethodPut, server.URL+apiPrefix+"/sensor/properties/temperature", "secret", `{"value":"30"}`)
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Equal(t, "21", driver.values["temperature"])
+
+	code, _ = request(t, http.MethodPut, server.URL+apiPrefix+"/sensor/properties/switch", "secret", `{"value":"on"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "on", driver.values["switch"])
+
+	code, _ = request(t, http.MethodGet, server.URL+apiPrefix+"/sensor/properties/pressure", "secret", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = request(t, http.MethodGet, server.URL+apiPrefix+"/unknown", "secret", "")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestServerDeviceFailed(t *testing.T) {
+	m, driver := newTestMapper(t)
+	server := httptest.NewServer(m.handler())
+	defer server.Close()
+	dev, _ := m.Device("sensor")
+
+	// The device failed to connect, it is answered without reaching the driver.
+	dev.setStarted(false)
+	dev.err = errors.New("connect: connection refused")
+	code, result := request(t, http.MethodGet, server.URL+apiPrefix+"/sensor/properties/temperature", "secret", "")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "connect: connection refused", result["error"])
+	code, _ = request(t, http.MethodPut, server.URL+apiPrefix+"/sensor/properties/switch", "secret", `{"value":"on"}`)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "", driver.values["switch"])
+
+	dev.err = nil
+	code, result = request(t, http.MethodGet, server.URL+apiPrefix+"/sensor/properties/temperature", "secret", "")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "device is not started", result["error"])
+
+	// The status of the device is still served.
+	code, _ = request(t, http.MethodGet, server.URL+apiPrefix+"/sensor", "secret", "")
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestServerDeviceStarting(t *testing.T) {
+	m, _ := newTestMapper(t)
+	server := httptest.NewServer(m.handler())
+	defer server.Close()
+	dev, _ := m.Device("sensor")
+	dev.setStarted(false)
+
+	// The properties are built while the device is requested, as when it starts.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			assert.Nil(t, dev.initProperties())
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		code, _ := request(t, http.MethodGet, server.URL+apiPrefix+"/sensor/properties/temperature", "secret", "")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		code, _ = request(t, http.MethodGet, server.URL+apiPrefix+"/sensor", "secret", "")
+		assert.Equal(t, http.StatusOK, code)
+	}
+	<-done
+}
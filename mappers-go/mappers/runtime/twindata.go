@@ -0,0 +1,203 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+// TwinData is the timer structure for getting the twin and data properties of a device
+// which share a collect cycle. They are published together in one twin update and one
+// data message.
+type TwinData struct {
+	Device *Device
+	Cycle  time.Duration
+	Items  []CollectItem
+}
+
+// CollectItem is a property collected by TwinData.
+type CollectItem struct {
+	Property *Property
+	// ReportTo is ReportToTwin or ReportToData.
+	ReportTo string
+}
+
+// Run timer function.
+func (td *TwinData) Run() {
+	start := time.Now()
+	defer func() {
+		if time.Since(start) > td.Cycle {
+			klog.Warningf("Get the %v properties of %s took longer than their collect cycle", td.Cycle, td.Device.Instance.ID)
+			td.Device.mapper.metrics.observeOverrun(td.Device, td.name())
+		}
+	}()
+
+	b := &batch{}
+	for _, item := range td.Items {
+		value, err := td.Device.Read(item.Property)
+		if err != nil {
+			klog.Errorf("Get %s of %s failed: %v", item.Property.Name, td.Device.Instance.ID, err)
+			continue
+		}
+		if err = td.Device.collect(b, item.ReportTo, item.Property, value); err != nil {
+			klog.Error(err)
+			continue
+		}
+		klog.V(1).Infof("Get the %s value as %s", item.Property.Name, value)
+	}
+	td.Device.publishBatch(b)
+}
+
+// name return the name of the timer.
+func (td *TwinData) name() string {
+	return fmt.Sprintf("collect %v", td.Cycle)
+}
+
+// initCollect start a timer for each collect cycle of the polled twin and data properties.
+// Write only properties are not polled.
+func (d *Device) initCollect() {
+	if !d.polled() {
+		return
+	}
+	for _, td := range d.collections() {
+		d.startTimer(td.name(), td.Run, td.Cycle)
+	}
+}
+
+// collections return the properties collected together by collect cycle, sorted by cycle.
+// A property which is a twin and a data property is collected once, for the twin.
+func (d *Device) collections() []*TwinData {
+	collections := make(map[time.Duration]*TwinData)
+	collected := make(map[string]bool)
+	add := func(prop *Property, reportTo string) {
+		if prop.AccessMode() == common.AccessModeWriteOnly || collected[prop.Name] {
+			return
+		}
+		collected[prop.Name] = true
+		cycle := collectCycle(prop)
+		td, ok := collections[cycle]
+		if !ok {
+			td = &TwinData{Device: d, Cycle: cycle}
+			collections[cycle] = td
+		}
+		td.Items = append(td.Items, CollectItem{Property: prop, ReportTo: reportTo})
+	}
+	for i := 0; i < len(d.Instance.Twins); i++ {
+		if prop, ok := d.Properties[d.Instance.Twins[i].PropertyName]; ok {
+			add(prop, ReportToTwin)
+		}
+	}
+	for i := 0; i < len(d.Instance.Datas.Properties); i++ {
+		if prop, ok := d.Properties[d.Instance.Datas.Properties[i].PropertyName]; ok {
+			add(prop, ReportToData)
+		}
+	}
+
+	sorted := make([]*TwinData, 0, len(collections))
+	for _, td := range collections {
+		sorted = append(sorted, td)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cycle < sorted[j].Cycle })
+	return sorted
+}
+
+// batch is the property values published together in one twin update and one data message.
+type batch struct {
+	twins     []common.PropertyValue
+	twinProps []*Property
+	datas     []common.PropertyValue
+	dataProps []*Property
+	// reported is the value recorded as reported for a property, instead of the published
+	// one, like the last sample of a JSON array of samples.
+	reported map[*Property]string
+}
+
+// add add a property value of the data type to the twin update or the data message.
+func (b *batch) add(reportTo string, prop *Property, dataType string, value string) {
+	pv := common.PropertyValue{Name: prop.Name, Type: dataType, Value: value}
+	if reportTo == ReportToData {
+		b.datas = append(b.datas, pv)
+		b.dataProps = append(b.dataProps, prop)
+	} else {
+		b.twins = append(b.twins, pv)
+		b.twinProps = append(b.twinProps, prop)
+	}
+}
+
+// addSamples add the JSON array of samples of the property to the data message. The last
+// sample is recorded as reported if the property is reported to data, a twin property
+// records the value of its twin instead.
+func (b *batch) addSamples(prop *Property, samples string, last string) {
+	b.datas = append(b.datas, common.PropertyValue{Name: prop.Name, Type: "string", Value: samples})
+	if prop.ReportTo != ReportToData {
+		b.dataProps = append(b.dataProps, nil)
+		return
+	}
+	b.dataProps = append(b.dataProps, prop)
+	if b.reported == nil {
+		b.reported = make(map[*Property]string)
+	}
+	b.reported[prop] = last
+}
+
+// publishBatch publish the twin update and the data message of the batch, if they aren't empty.
+func (d *Device) publishBatch(b *batch) {
+	if len(b.twins) > 0 {
+		topic := fmt.Sprintf(common.TopicTwinUpdate, d.Instance.ID)
+		if payload, err := common.CreateMessageTwinUpdates(b.twins); err != nil {
+			klog.Errorf("Create message twin update failed: %v", err)
+		} else {
+			d.publishProps(topic, payload, ReportToTwin, b.twins, b.twinProps, b.reported)
+		}
+	}
+	if len(b.datas) > 0 {
+		topic := fmt.Sprintf(common.TopicDataUpdate, d.Instance.ID)
+		if payload, err := common.CreateMessageDatas(b.datas); err != nil {
+			klog.Errorf("Create message data failed: %v", err)
+		} else {
+			d.publishProps(topic, payload, ReportToData, b.datas, b.dataProps, b.reported)
+		}
+	}
+}
+
+// publishProps publish the message of the property values and record them as reported,
+// or their value in reported. The values without a property aren't recorded.
+func (d *Device) publishProps(topic string, payload []byte, kind string, values []common.PropertyValue,
+	props []*Property, reported map[*Property]string) {
+	err := d.mapper.MqttClient.Publish(topic, payload)
+	d.mapper.metrics.observePublish(d, kind, err)
+	if err != nil {
+		klog.Errorf("Publish topic %v failed, err: %v", topic, err)
+		return
+	}
+	for i, prop := range props {
+		if prop == nil {
+			continue
+		}
+		if value, ok := reported[prop]; ok {
+			prop.setReported(value)
+			continue
+		}
+		prop.setReported(values[i].Value)
+	}
+}
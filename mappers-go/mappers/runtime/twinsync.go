@@ -0,0 +1,132 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"encoding/json"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"k8s.io/klog/v2"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+// syncTwin ask edgecore for the current twin of the device. The desired values the cloud
+// set while the mapper was down are applied when the result comes.
+func (d *Device) syncTwin() {
+	payload, err := common.CreateMessageTwinGet()
+	if err != nil {
+		klog.Errorf("Create message twin get failed: %v", err)
+		return
+	}
+	topic := d.mapper.Topic(common.TopicTwinGet, d.Instance.ID)
+	if err = d.mapper.MqttClient.Publish(topic, payload); err != nil {
+		klog.Errorf("Publish topic %v failed, err: %v", topic, err)
+		return
+	}
+	klog.V(1).Infof("Get twin of %s", d.Instance.ID)
+}
+
+// onTwinResult callback function of the twin get result. The outstanding desired values are
+// written to the device, unless they are older than the ones applied, then the actual values
+// of the twins are reported.
+func (m *Mapper) onTwinResult(client mqtt.Client, message mqtt.Message) {
+	id := common.GetTwinResultDeviceID(message.Topic())
+	if id == "" {
+		klog.Error("Wrong topic")
+		return
+	}
+	dev, ok := m.Device(id)
+	if !ok {
+		klog.Error("Device not exist")
+		return
+	}
+
+	var result common.DeviceTwinResult
+	if err := json.Unmarshal(message.Payload(), &result); err != nil {
+		klog.Errorf("Unmarshal message failed: %v", err)
+		return
+	}
+	klog.V(2).Infof("Receive twin result of %s: %s", id, message.Payload())
+	for name, twin := range result.Twin {
+		if twin == nil || twin.Expected == nil || twin.Expected.Value == nil {
+			continue
+		}
+		if !m.acceptVersion(dev, name, twin) {
+			continue
+		}
+		dev.applyDesired(name, *twin.Expected.Value, twinVersion(twin))
+	}
+	dev.goReportTwins()
+}
+
+// goReportTwins read the twins which aren't write only and report their actual values
+// in background.
+func (d *Device) goReportTwins() {
+	if d.ctx == nil || d.ctx.Err() != nil {
+		return
+	}
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		for i := 0; i < len(d.Instance.Twins); i++ {
+			prop, ok := d.Properties[d.Instance.Twins[i].PropertyName]
+			if !ok || prop.AccessMode() == common.AccessModeWriteOnly {
+				continue
+			}
+			value, err := d.Read(prop)
+			if err != nil {
+				klog.V(2).Infof("Get %s of %s failed: %v", prop.Name, d.Instance.ID, err)
+				continue
+			}
+			if err = d.publish(ReportToTwin, prop, prop.DataType, value); err != nil {
+				klog.Error(err)
+				continue
+			}
+			prop.setReported(value)
+		}
+	}()
+}
+
+// resync subscribe the topics of the started devices again and get their twins, after
+// the connection to edgecore was lost. Subscriber drivers subscribe their devices again.
+func (m *Mapper) resync() {
+	klog.Info("Reconnected to the mqtt broker, sync the twins")
+	for _, dev := range m.Devices() {
+		if !dev.Started() {
+			continue
+		}
+		if subscriber, ok := m.Driver.(Subscriber); ok {
+			if err := subscriber.Subscribe(dev); err != nil {
+				klog.Errorf("Subscribe device %v error: %v", dev.Instance.ID, err)
+			}
+		}
+		if err := m.initSubscribeMqtt(dev.Instance.ID); err != nil {
+			klog.Errorf("Subscribe mqtt of %v error: %v", dev.Instance.ID, err)
+			continue
+		}
+		dev.syncTwin()
+	}
+}
+
+// twinVersion return the expected version of the twin, nil if it has none.
+func twinVersion(twin *common.MsgTwin) *common.TwinVersion {
+	if twin == nil {
+		return nil
+	}
+	return twin.ExpectedVersion
+}
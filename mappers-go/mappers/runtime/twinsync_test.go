@@ -0,0 +1,80 @@
+package runtime
+
+import (
+	"context"
+	"testing"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+func TestTwinTopics(t *testing.T) {
+	m := NewMapper(&fakeDriver{}, nil)
+	topic := m.Topic(common.TopicTwinGetResult, "sensor")
+	assert.Equal(t, "$hw/events/device/sensor/twin/get/result", topic)
+	assert.Equal(t, "sensor", common.GetTwinResultDeviceID(topic))
+	assert.Equal(t, "", common.GetTwinResultDeviceID("$hw/events/device/sensor/twin/update/delta"))
+
+	m.LocalTest = true
+	assert.Equal(t, "hw/events/device/sensor/twin/get", m.Topic(common.TopicTwinGet, "sensor"))
+}
+
+func TestApplyDesired(t *testing.T) {
+	m := NewMapper(&fakeDriver{values: make(map[string]string)}, nil)
+	dev := &Device{Instance: common.DeviceInstance{ID: "sensor",
+		Twins: []common.Twin{{PropertyName: "switch", Desired: common.DesiredData{Value: "on"}}}}, mapper: m}
+	assert.False(t, dev.applyDesired("speed", "1", nil))
+	// The desired value is unchanged, nothing is written.
+	assert.True(t, dev.applyDesired("switch", "on", nil))
+	assert.Equal(t, "on", dev.Instance.Twins[0].Desired.Value)
+
+	// The write of a stopped device is dropped, not left running.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dev.ctx = ctx
+	assert.True(t, dev.applyDesired("switch", "off", nil))
+	dev.wg.Wait()
+	assert.Equal(t, "off", dev.desiredValue(&dev.Instance.Twins[0]))
+	assert.Empty(t, m.Driver.(*fakeDriver).values)
+}
+
+func TestAcceptVersion(t *testing.T) {
+	m := NewMapper(&fakeDriver{values: make(map[string]string)}, nil)
+	dev := &Device{Instance: common.DeviceInstance{ID: "sensor"}, mapper: m}
+	version := func(cloud int64) *common.MsgTwin {
+		return &common.MsgTwin{ExpectedVersion: &common.TwinVersion{CloudVersion: cloud}}
+	}
+	assert.True(t, m.acceptVersion(dev, "switch", nil))
+	assert.True(t, m.acceptVersion(dev, "switch", version(2)))
+	// The version is recorded once the value is written.
+	assert.True(t, m.acceptVersion(dev, "switch", version(1)))
+	m.recordVersion(dev, "switch", version(2).ExpectedVersion)
+	assert.True(t, m.acceptVersion(dev, "switch", version(2)))
+	assert.False(t, m.acceptVersion(dev, "switch", version(1)))
+	assert.True(t, m.acceptVersion(dev, "speed", version(1)))
+	m.recordVersion(dev, "switch", version(3).ExpectedVersion)
+	m.recordVersion(dev, "switch", version(2).ExpectedVersion)
+	assert.False(t, m.acceptVersion(dev, "switch", version(2)))
+
+	// A device added again with the same ID doesn't keep the versions.
+	m.forgetDevice("sensor")
+	assert.True(t, m.acceptVersion(dev, "switch", version(1)))
+}
+
+func TestSetTwinVersion(t *testing.T) {
+	// The client isn't connected, its publishes fail at once.
+	m := NewMapper(&fakeDriver{values: make(map[string]string)},
+		&common.MqttClient{Client: mqtt.NewClient(mqtt.NewClientOptions())})
+	dev := &Device{Instance: common.DeviceInstance{ID: "sensor",
+		PropertyVisitors: []common.PropertyVisitor{{PropertyName: "switch",
+			PProperty: common.Property{DataType: "string", AccessMode: common.AccessModeReadWrite}}},
+		Twins: []common.Twin{{PropertyName: "switch"}}}, mapper: m}
+	assert.Nil(t, dev.initProperties())
+	assert.Equal(t, "", dev.desiredValue(&dev.Instance.Twins[0]))
+
+	dev.setTwin(&dev.Instance.Twins[0], "on", "", &common.TwinVersion{CloudVersion: 4})
+	assert.Equal(t, "on", m.Driver.(*fakeDriver).values["switch"])
+	assert.False(t, m.acceptVersion(dev, "switch", &common.MsgTwin{ExpectedVersion: &common.TwinVersion{CloudVersion: 3}}))
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"fmt"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+// initUnit check the device unit of the unit config can be converted to the unit of the
+// property in the device model.
+func (p *Property) initUnit(config common.UnitConfig) error {
+	if config.DeviceUnit == "" {
+		return nil
+	}
+	unit := p.Visitor.PProperty.Unit
+	if unit == "" {
+		return fmt.Errorf("deviceUnit %s is set, but the property has no unit", config.DeviceUnit)
+	}
+	if !common.IsNumeric(p.DataType) {
+		return fmt.Errorf("deviceUnit %s is set, but %s is not a numeric type", config.DeviceUnit, p.DataType)
+	}
+	if _, err := common.ConvertUnit(0, config.DeviceUnit, unit); err != nil {
+		return err
+	}
+	p.deviceUnit = config.DeviceUnit
+	return nil
+}
+
+// fromDeviceUnit convert a value read from the device to the unit of the property.
+func (p *Property) fromDeviceUnit(value string) (string, error) {
+	if p.deviceUnit == "" {
+		return value, nil
+	}
+	return common.ConvertUnitValue(p.DataType, value, p.deviceUnit, p.Visitor.PProperty.Unit)
+}
+
+// toDeviceUnit convert a value of the property to the unit of the device.
+func (p *Property) toDeviceUnit(value string) (string, error) {
+	if p.deviceUnit == "" {
+		return value, nil
+	}
+	return common.ConvertUnitValue(p.DataType, value, p.Visitor.PProperty.Unit, p.deviceUnit)
+}
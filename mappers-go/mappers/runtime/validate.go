@@ -0,0 +1,116 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+// ErrInvalidValue is wrapped by the errors of the values which are not written to the
+// device because they are not valid for the property.
+var ErrInvalidValue = errors.New("invalid value")
+
+// validator checks the values written to a property.
+type validator struct {
+	dataType string
+	// minimum and maximum are checked for numbers if maximum is greater than minimum.
+	minimum int64
+	maximum int64
+	enum    []string
+	// pattern is the compiled patternText, which matches the whole value.
+	pattern     *regexp.Regexp
+	patternText string
+}
+
+// newValidator return the validator of the property values.
+func newValidator(prop *Property, config common.ValidateConfig) (*validator, error) {
+	v := &validator{dataType: prop.DataType,
+		minimum: prop.Visitor.PProperty.Minimum,
+		maximum: prop.Visitor.PProperty.Maximum,
+		enum:    config.Enum}
+	if config.Pattern != "" {
+		pattern, err := regexp.Compile("^(?:" + config.Pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %v", config.Pattern, err)
+		}
+		v.pattern = pattern
+		v.patternText = config.Pattern
+	}
+	return v, nil
+}
+
+// validate check the value is of the data type, in the range, in the enum and matches
+// the pattern of the property. The values of the data types common.Convert doesn't know
+// are not converted.
+func (v *validator) validate(value string) error {
+	if _, err := common.Convert(v.dataType, value); err != nil && !errors.Is(err, common.ErrUnknownType) {
+		return fmt.Errorf("%w: %v", ErrInvalidValue, err)
+	}
+
+	if v.maximum > v.minimum && common.IsNumeric(v.dataType) {
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%w: %q is not a number", ErrInvalidValue, value)
+		}
+		if number < float64(v.minimum) || number > float64(v.maximum) {
+			return fmt.Errorf("%w: %s is out of range [%d, %d]", ErrInvalidValue, value, v.minimum, v.maximum)
+		}
+	}
+
+	if len(v.enum) > 0 {
+		found := false
+		for _, e := range v.enum {
+			if e == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: %q is not one of %v", ErrInvalidValue, value, v.enum)
+		}
+	}
+
+	if v.pattern != nil && !v.pattern.MatchString(value) {
+		return fmt.Errorf("%w: %q does not match %s", ErrInvalidValue, value, v.patternText)
+	}
+	return nil
+}
+
+// publishTwinError report the desired value of the twin which was rejected, with the
+// last value read from the device as the actual value.
+func (d *Device) publishTwinError(prop *Property, reason error) error {
+	var actual *string
+	if value, updated := prop.Value(); !updated.IsZero() {
+		actual = &value
+	}
+	payload, err := common.CreateMessageTwinError(prop.Name, prop.DataType, actual, reason.Error())
+	if err != nil {
+		return fmt.Errorf("create message twin error failed: %v", err)
+	}
+	topic := fmt.Sprintf(common.TopicTwinUpdate, d.Instance.ID)
+	err = d.mapper.MqttClient.Publish(topic, payload)
+	d.mapper.metrics.observePublish(d, ReportToTwin, err)
+	if err != nil {
+		return fmt.Errorf("publish topic %v failed, err: %v", topic, err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package runtime
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+func TestValidate(t *testing.T) {
+	prop := &Property{Name: "valve", DataType: "int",
+		Visitor: &common.PropertyVisitor{PProperty: common.Property{Minimum: 0, Maximum: 100}}}
+	v, err := newValidator(prop, common.ValidateConfig{})
+	assert.Nil(t, err)
+	assert.Nil(t, v.validate("50"))
+	assert.True(t, errors.Is(v.validate("9999"), ErrInvalidValue))
+	assert.True(t, errors.Is(v.validate("-1"), ErrInvalidValue))
+	assert.True(t, errors.Is(v.validate("half"), ErrInvalidValue))
+
+	prop = &Property{Name: "mode", DataType: "string", Visitor: &common.PropertyVisitor{}}
+	v, err = newValidator(prop, common.ValidateConfig{Enum: []string{"auto", "manual"}})
+	assert.Nil(t, err)
+	assert.Nil(t, v.validate("auto"))
+	assert.EqualError(t, v.validate("off"), `invalid value: "off" is not one of [auto manual]`)
+
+	v, err = newValidator(prop, common.ValidateConfig{Pattern: "[a-z]+"})
+	assert.Nil(t, err)
+	assert.Nil(t, v.validate("auto"))
+	assert.EqualError(t, v.validate("auto1"), `invalid value: "auto1" does not match [a-z]+`)
+
+	_, err = newValidator(prop, common.ValidateConfig{Pattern: "[a-z"})
+	assert.NotNil(t, err)
+}
+
+func TestWriteInvalid(t *testing.T) {
+	driver := &fakeDriver{values: make(map[string]string)}
+	m := NewMapper(driver, nil)
+	instance := common.DeviceInstance{ID: "valve",
+		PropertyVisitors: []common.PropertyVisitor{{PropertyName: "opening",
+			PProperty: common.Property{DataType: "int", Minimum: 0, Maximum: 100}}}}
+	dev := &Device{Instance: instance, mapper: m}
+	assert.Nil(t, dev.initProperties())
+
+	err := dev.Write(dev.Properties["opening"], "9999")
+	assert.True(t, errors.Is(err, ErrInvalidValue))
+	assert.Equal(t, "", driver.values["opening"])
+	assert.Nil(t, dev.Write(dev.Properties["opening"], "40"))
+	assert.Equal(t, "40", driver.values["opening"])
+}
+
+func TestDeviceUnit(t *testing.T) {
+	driver := &fakeDriver{values: map[string]string{"temperature": "212"}}
+	m := NewMapper(driver, nil)
+	instance := common.DeviceInstance{ID: "boiler",
+		PropertyVisitors: []common.PropertyVisitor{{PropertyName: "temperature",
+			VisitorConfig: json.RawMessage(`{"configData":{"deviceUnit":"°F"}}`),
+			PProperty:     common.Property{DataType: "double", Unit: "°C"}}}}
+	dev := &Device{Instance: instance, mapper: m}
+	assert.Nil(t, dev.initProperties())
+
+	value, err := dev.Read(dev.Properties["temperature"])
+	assert.Nil(t, err)
+	assert.Equal(t, "100", value)
+	assert.Nil(t, dev.Write(dev.Properties["temperature"], "0"))
+	assert.Equal(t, "32", driver.values["temperature"])
+
+	dev.Instance.PropertyVisitors[0].PProperty.Unit = "kPa"
+	assert.NotNil(t, dev.initProperties())
+}
+
+func TestExpressions(t *testing.T) {
+	driver := &fakeDriver{values: map[string]string{"level": "12000", "valve": "1"}}
+	m := NewMapper(driver, nil)
+	instance := common.DeviceInstance{ID: "tank",
+		PropertyVisitors: []common.PropertyVisitor{
+			{PropertyName: "level", PProperty: common.Property{DataType: "double"},
+				VisitorConfig: json.RawMessage(`{"configData":{"readExpression":"(raw - 4000) / 16000 * 100",
+					"writeExpression":"value / 100 * 16000 + 4000"}}`)},
+			{PropertyName: "valve", PProperty: common.Property{DataType: "string"},
+				VisitorConfig: json.RawMessage(`{"configData":{"readExpression":"raw > 0 ? \"open\" : \"closed\""}}`)}}}
+	dev := &Device{Instance: instance, mapper: m}
+	assert.Nil(t, dev.initProperties())
+
+	value, err := dev.Read(dev.Properties["level"])
+	assert.Nil(t, err)
+	assert.Equal(t, "50", value)
+	assert.Nil(t, dev.Write(dev.Properties["level"], "25"))
+	assert.Equal(t, "8000", driver.values["level"])
+
+	value, err = dev.Read(dev.Properties["valve"])
+	assert.Nil(t, err)
+	assert.Equal(t, "open", value)
+	err = dev.Write(dev.Properties["valve"], "closed")
+	assert.True(t, errors.Is(err, ErrInvalidValue))
+
+	dev.Instance.PropertyVisitors[1].VisitorConfig = json.RawMessage(`{"configData":{"readExpression":"raw >"}}`)
+	assert.NotNil(t, dev.initProperties())
+}
@@ -0,0 +1,378 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kubeedge/kubeedge/cloud/pkg/apis/devices/v1alpha2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+)
+
+// The protocol names and the data types, as the device controller of edgecore sets them.
+const (
+	protocolOpcUA      = "opcua"
+	protocolModbus     = "modbus"
+	protocolBluetooth  = "bluetooth"
+	protocolCustomized = "customized-protocol"
+
+	dataTypeInt     = "int"
+	dataTypeString  = "string"
+	dataTypeDouble  = "double"
+	dataTypeFloat   = "float"
+	dataTypeBoolean = "boolean"
+	dataTypeBytes   = "bytes"
+)
+
+// apiVersion is the API version of the manifests which are converted.
+const apiVersion = "devices.kubeedge.io/v1alpha2"
+
+// documentSeparator splits the YAML documents of a file.
+var documentSeparator = regexp.MustCompile(`(?m)^---[ \t]*$`)
+
+// manifests are the devices and the device models read from the YAML files. A manifest
+// replaces the one of the same name read before, as if they were applied in turn.
+type manifests struct {
+	devices []v1alpha2.Device
+	models  map[string]v1alpha2.DeviceModel
+	// indexes are the indexes of the devices by name.
+	indexes map[string]int
+}
+
+// newManifests allocate and return empty manifests.
+func newManifests() *manifests {
+	return &manifests{models: make(map[string]v1alpha2.DeviceModel),
+		indexes: make(map[string]int)}
+}
+
+// readManifests read the Device and DeviceModel manifests of the files, and of the .yaml
+// and .yml files of the directories. Other kinds of manifests are skipped.
+func readManifests(paths []string) (*manifests, error) {
+	m := newManifests()
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		files := []string{path}
+		if info.IsDir() {
+			if files, err = yamlFiles(path); err != nil {
+				return nil, err
+			}
+		}
+		for _, file := range files {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			if err = m.decode(data); err != nil {
+				return nil, fmt.Errorf("%s: %v", file, err)
+			}
+		}
+	}
+	return m, nil
+}
+
+// yamlFiles return the YAML files of the directory, sorted by name.
+func yamlFiles(dir string) ([]string, error) {
+	var files []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// decode add the devices and the device models of the YAML documents.
+func (m *manifests) decode(data []byte) error {
+	for i, doc := range documentSeparator.Split(string(data), -1) {
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+		var meta metav1.TypeMeta
+		if err := yaml.Unmarshal([]byte(doc), &meta); err != nil {
+			return fmt.Errorf("document %d: %v", i, err)
+		}
+		if meta.APIVersion != apiVersion {
+			continue
+		}
+		switch meta.Kind {
+		case "Device":
+			var device v1alpha2.Device
+			if err := yaml.Unmarshal([]byte(doc), &device); err != nil {
+				return fmt.Errorf("document %d: %v", i, err)
+			}
+			if j, ok := m.indexes[device.Name]; ok {
+				m.devices[j] = device
+				continue
+			}
+			m.indexes[device.Name] = len(m.devices)
+			m.devices = append(m.devices, device)
+		case "DeviceModel":
+			var model v1alpha2.DeviceModel
+			if err := yaml.Unmarshal([]byte(doc), &model); err != nil {
+				return fmt.Errorf("document %d: %v", i, err)
+			}
+			m.models[model.Name] = model
+		}
+	}
+	return nil
+}
+
+// nodeName return the node the device is bound to by its node selector, as edgecore
+// takes it.
+func nodeName(device *v1alpha2.Device) string {
+	selector := device.Spec.NodeSelector
+	if selector == nil || len(selector.NodeSelectorTerms) == 0 ||
+		len(selector.NodeSelectorTerms[0].MatchExpressions) == 0 ||
+		len(selector.NodeSelectorTerms[0].MatchExpressions[0].Values) == 0 {
+		return ""
+	}
+	return selector.NodeSelectorTerms[0].MatchExpressions[0].Values[0]
+}
+
+// buildProfile build the device profile of the devices on the node, or of all devices if
+// node is empty, with the protocols and the device models they refer to.
+func (m *manifests) buildProfile(node string) (common.DeviceProfile, error) {
+	var profile common.DeviceProfile
+	added := make(map[string]bool)
+	for i := range m.devices {
+		device := &m.devices[i]
+		if node != "" && nodeName(device) != node {
+			continue
+		}
+		if device.Spec.DeviceModelRef == nil {
+			return profile, fmt.Errorf("device %s has no deviceModelRef", device.Name)
+		}
+		modelName := device.Spec.DeviceModelRef.Name
+		model, ok := m.models[modelName]
+		if !ok {
+			return profile, fmt.Errorf("device model %s of device %s not found", modelName, device.Name)
+		}
+
+		instance, protocol, err := newInstance(device)
+		if err != nil {
+			return profile, fmt.Errorf("device %s: %v", device.Name, err)
+		}
+		profile.DeviceInstances = append(profile.DeviceInstances, instance)
+		if protocol.Name != "" {
+			profile.Protocols = append(profile.Protocols, protocol)
+		} else {
+			klog.Warningf("Device %s has no supported protocol", device.Name)
+		}
+		if !added[modelName] {
+			dm, err := newModel(&model)
+			if err != nil {
+				return profile, fmt.Errorf("device model %s: %v", modelName, err)
+			}
+			profile.DeviceModels = append(profile.DeviceModels, dm)
+			added[modelName] = true
+		}
+	}
+	return profile, nil
+}
+
+// newInstance convert the device to the device instance and its protocol. The protocol
+// has no name if the device has no supported protocol.
+func newInstance(device *v1alpha2.Device) (common.DeviceInstance, common.Protocol, error) {
+	instance := common.DeviceInstance{ID: device.Name,
+		Name:  device.Name,
+		Model: device.Spec.DeviceModelRef.Name}
+	var protocol common.Protocol
+	var config interface{}
+	switch spec := device.Spec.Protocol; {
+	case spec.OpcUA != nil:
+		protocol.Protocol, config = protocolOpcUA, spec.OpcUA
+	case spec.Modbus != nil:
+		protocol.Protocol, config = protocolModbus, spec.Modbus
+	case spec.Bluetooth != nil:
+		protocol.Protocol, config = protocolBluetooth, spec.Bluetooth
+	case spec.CustomizedProtocol != nil:
+		protocol.Protocol, config = protocolCustomized, spec.CustomizedProtocol
+	}
+	var err error
+	if config != nil {
+		protocol.Name = protocol.Protocol + "-" + device.Name
+		instance.ProtocolName = protocol.Name
+		if protocol.ProtocolConfigs, err = json.Marshal(config); err != nil {
+			return instance, protocol, err
+		}
+	}
+	if device.Spec.Protocol.Common != nil {
+		if protocol.ProtocolCommonConfig, err = json.Marshal(device.Spec.Protocol.Common); err != nil {
+			return instance, protocol, err
+		}
+	}
+
+	for _, twin := range device.Status.Twins {
+		instance.Twins = append(instance.Twins, common.Twin{PropertyName: twin.PropertyName,
+			Desired: common.DesiredData{Value: twin.Desired.Value,
+				Metadatas: common.Metadata{Timestamp: twin.Desired.Metadata["timestamp"],
+					Type: twin.Desired.Metadata["type"]}},
+			Reported: common.ReportedData{Value: twin.Reported.Value,
+				Metadatas: common.Metadata{Timestamp: twin.Reported.Metadata["timestamp"],
+					Type: twin.Reported.Metadata["type"]}}})
+	}
+	instance.Datas.Topic = device.Spec.Data.DataTopic
+	for _, data := range device.Spec.Data.DataProperties {
+		timestamp, _ := strconv.ParseInt(data.Metadata["timestamp"], 10, 64)
+		instance.Datas.Properties = append(instance.Datas.Properties, common.DataProperty{
+			PropertyName: data.PropertyName,
+			Metadatas:    common.DataMetadata{Timestamp: timestamp, Type: data.Metadata["type"]}})
+	}
+
+	for _, pptv := range device.Spec.PropertyVisitors {
+		visitor := common.PropertyVisitor{Name: pptv.PropertyName,
+			PropertyName: pptv.PropertyName,
+			ModelName:    instance.Model,
+			ReportCycle:  pptv.ReportCycle,
+			CollectCycle: pptv.CollectCycle}
+		var config interface{}
+		switch {
+		case pptv.OpcUA != nil:
+			visitor.Protocol, config = protocolOpcUA, pptv.OpcUA
+		case pptv.Modbus != nil:
+			visitor.Protocol, config = protocolModbus, pptv.Modbus
+		case pptv.Bluetooth != nil:
+			visitor.Protocol, config = protocolBluetooth, pptv.Bluetooth
+		case pptv.CustomizedProtocol != nil:
+			visitor.Protocol, config = protocolCustomized, pptv.CustomizedProtocol
+		}
+		if config != nil {
+			if visitor.VisitorConfig, err = json.Marshal(config); err != nil {
+				return instance, protocol, err
+			}
+		}
+		instance.PropertyVisitors = append(instance.PropertyVisitors, visitor)
+	}
+	return instance, protocol, nil
+}
+
+// newModel convert the device model, with the data type and the range of each property.
+// The range of the device profile is integer, so a float range which isn't is refused
+// instead of truncated.
+func newModel(model *v1alpha2.DeviceModel) (common.DeviceModel, error) {
+	dm := common.DeviceModel{Name: model.Name}
+	for _, ppt := range model.Spec.Properties {
+		property := common.Property{Name: ppt.Name, Description: ppt.Description}
+		var err error
+		switch t := ppt.Type; {
+		case t.Int != nil:
+			property.AccessMode = string(t.Int.AccessMode)
+			property.DataType = dataTypeInt
+			property.DefaultValue = t.Int.DefaultValue
+			property.Minimum = t.Int.Minimum
+			property.Maximum = t.Int.Maximum
+			property.Unit = t.Int.Unit
+		case t.String != nil:
+			property.AccessMode = string(t.String.AccessMode)
+			property.DataType = dataTypeString
+			property.DefaultValue = t.String.DefaultValue
+		case t.Double != nil:
+			property.AccessMode = string(t.Double.AccessMode)
+			property.DataType = dataTypeDouble
+			property.DefaultValue = t.Double.DefaultValue
+			if property.Minimum, property.Maximum, err = integerRange(t.Double.Minimum, t.Double.Maximum); err != nil {
+				return dm, fmt.Errorf("property %s: %v", ppt.Name, err)
+			}
+			property.Unit = t.Double.Unit
+		case t.Float != nil:
+			property.AccessMode = string(t.Float.AccessMode)
+			property.DataType = dataTypeFloat
+			property.DefaultValue = t.Float.DefaultValue
+			if property.Minimum, property.Maximum, err = integerRange(float64(t.Float.Minimum),
+				float64(t.Float.Maximum)); err != nil {
+				return dm, fmt.Errorf("property %s: %v", ppt.Name, err)
+			}
+			property.Unit = t.Float.Unit
+		case t.Boolean != nil:
+			property.AccessMode = string(t.Boolean.AccessMode)
+			property.DataType = dataTypeBoolean
+			property.DefaultValue = t.Boolean.DefaultValue
+		case t.Bytes != nil:
+			property.AccessMode = string(t.Bytes.AccessMode)
+			property.DataType = dataTypeBytes
+		}
+		dm.Properties = append(dm.Properties, property)
+	}
+	return dm, nil
+}
+
+// integerRange return the minimum and maximum as the integers of the device profile, an
+// error if one of them isn't an integer.
+func integerRange(minimum float64, maximum float64) (int64, int64, error) {
+	for _, bound := range []float64{minimum, maximum} {
+		if bound != math.Trunc(bound) || math.Abs(bound) >= math.MaxInt64 {
+			return 0, 0, fmt.Errorf("range [%v, %v] isn't integer, the device profile can't keep it",
+				minimum, maximum)
+		}
+	}
+	return int64(minimum), int64(maximum), nil
+}
+
+// linkedFields are the fields of the common types which the mappers set when they parse
+// the profile. They are not in the configmap.
+var linkedFields = map[string]bool{"PProtocol": true, "PProperty": true, "PVisitor": true}
+
+// marshalProfile return the indented JSON of the profile, without the linked fields.
+func marshalProfile(profile common.DeviceProfile) ([]byte, error) {
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err = json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(removeLinked(value), "", "    ")
+}
+
+// removeLinked remove the linked fields from the JSON value.
+func removeLinked(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if linkedFields[key] {
+				delete(v, key)
+				continue
+			}
+			v[key] = removeLinked(field)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = removeLinked(v[i])
+		}
+	}
+	return value
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kubeedge/kubeedge/cloud/pkg/apis/devices/v1alpha2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kubeedge/mappers-go/mappers/common"
+	"github.com/kubeedge/mappers-go/mappers/runtime"
+)
+
+const coapManifests = `apiVersion: devices.kubeedge.io/v1alpha2
+kind: DeviceModel
+metadata:
+  name: coap-sample-model
+spec:
+  properties:
+  - name: temperature
+    type:
+      int:
+        accessMode: ReadWrite
+        maximum: 100
+        unit: degree celsius
+  - name: humidity
+    type:
+      double:
+        accessMode: ReadOnly
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: skipped
+---
+apiVersion: devices.kubeedge.io/v1alpha2
+kind: Device
+metadata:
+  name: coap-device
+spec:
+  deviceModelRef:
+    name: coap-sample-model
+  protocol:
+    customizedProtocol:
+      protocolName: coap
+      configData:
+        server: 127.0.0.1:5683
+  nodeSelector:
+    nodeSelectorTerms:
+    - matchExpressions:
+      - key: ''
+        operator: In
+        values:
+        - edge120
+  propertyVisitors:
+  - propertyName: temperature
+    collectCycle: 5000
+    customizedProtocol:
+      protocolName: coap
+      configData:
+        pathField: temperature
+  - propertyName: humidity
+    customizedProtocol:
+      protocolName: coap
+      configData:
+        pathField: humidity
+  data:
+    dataTopic: "$ke/events/device/+/data/update"
+    dataProperties:
+    - propertyName: humidity
+      metadata:
+        type: double
+        timestamp: '1550049403598'
+status:
+  twins:
+  - propertyName: temperature
+    desired:
+      metadata:
+        type: integer
+      value: "20"
+`
+
+func TestBuildProfile(t *testing.T) {
+	m := newManifests()
+	assert.Nil(t, m.decode([]byte(coapManifests)))
+	assert.Equal(t, 1, len(m.devices))
+
+	profile, err := m.buildProfile("edge120")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(profile.DeviceInstances))
+	instance := profile.DeviceInstances[0]
+	assert.Equal(t, "customized-protocol-coap-device", instance.ProtocolName)
+	assert.Equal(t, "coap-sample-model", instance.PropertyVisitors[0].ModelName)
+	assert.Equal(t, int64(5000), instance.PropertyVisitors[0].CollectCycle)
+	assert.Equal(t, "customized-protocol", instance.PropertyVisitors[0].Protocol)
+	assert.JSONEq(t, `{"protocolName":"coap","configData":{"pathField":"temperature"}}`,
+		string(instance.PropertyVisitors[0].VisitorConfig))
+	assert.Equal(t, []common.Twin{{PropertyName: "temperature",
+		Desired: common.DesiredData{Value: "20", Metadatas: common.Metadata{Type: "integer"}}}}, instance.Twins)
+	assert.Equal(t, common.Data{Topic: "$ke/events/device/+/data/update",
+		Properties: []common.DataProperty{{PropertyName: "humidity",
+			Metadatas: common.DataMetadata{Timestamp: 1550049403598, Type: "double"}}}}, instance.Datas)
+	assert.JSONEq(t, `{"protocolName":"coap","configData":{"server":"127.0.0.1:5683"}}`,
+		string(profile.Protocols[0].ProtocolConfigs))
+	assert.Equal(t, []common.Property{
+		{Name: "temperature", DataType: "int", AccessMode: "ReadWrite", DefaultValue: int64(0), Maximum: 100, Unit: "degree celsius"},
+		{Name: "humidity", DataType: "double", AccessMode: "ReadOnly", DefaultValue: float64(0)}},
+		profile.DeviceModels[0].Properties)
+
+	data, err := marshalProfile(profile)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "PProtocol")
+	assert.Contains(t, string(data), `"protocol": "customized-protocol-coap-device"`)
+
+	profile, err = m.buildProfile("edge121")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(profile.DeviceInstances))
+}
+
+func TestSamples(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profilegen")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "deviceProfile.json")
+	assert.Nil(t, run([]string{"../../../build/crd-samples/devices"}, "", path))
+
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "PVisitor")
+	var profile common.DeviceProfile
+	assert.Nil(t, json.Unmarshal(data, &profile))
+
+	// The profile is parsed by the mappers as the configmap of edgecore.
+	devices := make(map[string]*runtime.Device)
+	models := make(map[string]common.DeviceModel)
+	protocols := make(map[string]common.Protocol)
+	assert.Nil(t, runtime.Parse(path, devices, models, protocols))
+	assert.Equal(t, len(profile.DeviceInstances), len(devices))
+	assert.Nil(t, devices["modbustcp-device"].Err())
+	assert.Equal(t, "modbus", devices["modbustcp-device"].Instance.PProtocol.Protocol)
+	assert.Equal(t, "int", devices["modbustcp-device"].Instance.PropertyVisitors[0].PProperty.DataType)
+}
+
+func TestNewModelRange(t *testing.T) {
+	model := func(minimum float64, maximum float64) *v1alpha2.DeviceModel {
+		dm := &v1alpha2.DeviceModel{}
+		dm.Name = "sensor-model"
+		dm.Spec.Properties = []v1alpha2.DeviceProperty{{Name: "humidity", Type: v1alpha2.PropertyType{
+			Double: &v1alpha2.PropertyTypeDouble{Minimum: minimum, Maximum: maximum}}}}
+		return dm
+	}
+
+	dm, err := newModel(model(0, 100))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(100), dm.Properties[0].Maximum)
+
+	// The range isn't truncated.
+	_, err = newModel(model(0.5, 1.5))
+	assert.EqualError(t, err, "property humidity: range [0.5, 1.5] isn't integer, the device profile can't keep it")
+}
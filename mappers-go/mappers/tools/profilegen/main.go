@@ -0,0 +1,70 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command profilegen converts v1alpha2 Device and DeviceModel manifests into the
+// deviceProfile.json of the configmap edgecore generates, to run the mappers without
+// a cluster.
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/pflag"
+)
+
+func main() {
+	var output, node string
+	pflag.StringVarP(&output, "output", "o", "", "file to write the device profile to, the standard output by default")
+	pflag.StringVar(&node, "node", "", "only convert the devices bound to the node by their node selector")
+	pflag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] <file or directory>...\n", os.Args[0])
+		pflag.PrintDefaults()
+	}
+	pflag.Parse()
+	if pflag.NArg() == 0 {
+		pflag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(pflag.Args(), node, output); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run convert the manifests of the paths and write the device profile to the output.
+func run(paths []string, node string, output string) error {
+	m, err := readManifests(paths)
+	if err != nil {
+		return err
+	}
+	profile, err := m.buildProfile(node)
+	if err != nil {
+		return err
+	}
+	data, err := marshalProfile(profile)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return ioutil.WriteFile(output, data, 0644)
+}